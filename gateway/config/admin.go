@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type configView struct {
+	Port             string             `json:"port"`
+	RoutesFile       string             `json:"routesFile,omitempty"`
+	UserServiceURL   string             `json:"userServiceUrl"`
+	OrderServiceURL  string             `json:"orderServiceUrl"`
+	RateLimitStore   string             `json:"rateLimitStore"`
+	RateLimitRPS     int                `json:"rateLimitRps"`
+	RateLimitBurst   int                `json:"rateLimitBurst"`
+	RateLimitIPRPS   int                `json:"rateLimitIpRps"`
+	RateLimitIPBurst int                `json:"rateLimitIpBurst"`
+	RateLimitTiers   map[string]float64 `json:"rateLimitTiers"`
+	TrustedProxies   []string           `json:"trustedProxies"`
+	QuotaDaily       int64              `json:"quotaDaily"`
+	QuotaMonthly     int64              `json:"quotaMonthly"`
+	UsageStore       string             `json:"usageStore"`
+	APIKeys          []string           `json:"apiKeys"`
+	JWKSURL          string             `json:"jwksUrl"`
+}
+
+// StatusHandler отдаёт действующую конфигурацию и результат последней перезагрузки
+func (m *Manager) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := m.Current()
+	// Только id: хеши ключей наружу не отдаём
+	apiKeys := make([]string, 0, len(cfg.APIKeys))
+	for _, key := range cfg.APIKeys {
+		apiKeys = append(apiKeys, key.ID)
+	}
+	trustedProxies := make([]string, 0, len(cfg.TrustedProxies))
+	for _, ipNet := range cfg.TrustedProxies {
+		trustedProxies = append(trustedProxies, ipNet.String())
+	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"reload": m.Status(),
+			"config": configView{
+				Port:             cfg.Port,
+				RoutesFile:       cfg.RoutesFile,
+				UserServiceURL:   cfg.UserServiceURL,
+				OrderServiceURL:  cfg.OrderServiceURL,
+				RateLimitStore:   cfg.RateLimitStore,
+				RateLimitRPS:     cfg.RateLimitRPS,
+				RateLimitBurst:   cfg.RateLimitBurst,
+				RateLimitIPRPS:   cfg.RateLimitIPRPS,
+				RateLimitIPBurst: cfg.RateLimitIPBurst,
+				RateLimitTiers:   cfg.RateLimitTiers,
+				TrustedProxies:   trustedProxies,
+				QuotaDaily:       cfg.QuotaDaily,
+				QuotaMonthly:     cfg.QuotaMonthly,
+				UsageStore:       cfg.UsageStore,
+				APIKeys:          apiKeys,
+				JWKSURL:          cfg.JWKSURL,
+			},
+		},
+	})
+}
+
+// ReloadHandler перезагружает конфигурацию по запросу администратора
+func (m *Manager) ReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if err := m.Reload("admin API"); err != nil {
+		respond(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"success": false,
+			"error": map[string]string{
+				"code":    "CONFIG_REJECTED",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    m.Status(),
+	})
+}
+
+func respond(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"gateway/middleware"
+
+	"gopkg.in/yaml.v3"
+)
+
+var apiKeyIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Файл ключей API (API_KEYS_FILE), например:
+//
+//	keys:
+//	  - id: billing-team
+//	    sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	    quota:
+//	      daily: 10000
+//	      monthly: 200000
+type apiKeysFile struct {
+	Keys []struct {
+		ID     string `yaml:"id"`
+		SHA256 string `yaml:"sha256"`
+		Quota  struct {
+			Daily   int64 `yaml:"daily"`
+			Monthly int64 `yaml:"monthly"`
+		} `yaml:"quota"`
+	} `yaml:"keys"`
+}
+
+// loadAPIKeys читает ключи API; пустой путь — ключей нет.
+// Файл перечитывается при каждой перезагрузке конфигурации, так что ключ можно отозвать без рестарта.
+func loadAPIKeys(path string) ([]middleware.APIKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys file: %w", err)
+	}
+
+	var file apiKeysFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse API keys: %w", err)
+	}
+
+	var errs []error
+	keys := make([]middleware.APIKey, 0, len(file.Keys))
+	ids := make(map[string]bool)
+	hashes := make(map[string]bool)
+	for i, entry := range file.Keys {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Errorf("key #%d (%s): %s", i+1, entry.ID, fmt.Sprintf(format, args...)))
+		}
+
+		if !apiKeyIDPattern.MatchString(entry.ID) {
+			fail("id must be 1-64 letters, digits, '.', '_' or '-'")
+		} else if ids[entry.ID] {
+			fail("duplicate id")
+		}
+		ids[entry.ID] = true
+
+		hash := strings.ToLower(strings.TrimSpace(entry.SHA256))
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != 32 {
+			fail("sha256 must be a hex-encoded SHA-256 of the key")
+		} else if hashes[hash] {
+			fail("the same key is listed twice")
+		}
+		hashes[hash] = true
+
+		if entry.Quota.Daily < 0 || entry.Quota.Monthly < 0 {
+			fail("quota cannot be negative")
+		}
+
+		keys = append(keys, middleware.APIKey{
+			ID:     entry.ID,
+			SHA256: hash,
+			Limits: middleware.QuotaLimits{Daily: entry.Quota.Daily, Monthly: entry.Quota.Monthly},
+		})
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"gateway/middleware"
+	"gateway/proxy"
+)
+
+type Config struct {
+	Port       string
+	RoutesFile string
+	// Публичные ключи user-service для проверки JWT
+	JWKSURL             string
+	JWKSRefreshInterval time.Duration
+	// Список отозванных токенов user-service
+	RevocationsURL string
+	// Ключ, которым шлюз подписывает X-Internal-Identity для сервисов
+	InternalIdentityKeyFile string
+
+	// Исходные значения для логов и /admin/config
+	UserServiceURL  string
+	OrderServiceURL string
+
+	Proxy proxy.Config
+
+	// memory или redis
+	RateLimitStore     string
+	RateLimitKeyPrefix string
+	RedisURL           string
+	RateLimitRPS       int
+	RateLimitBurst     int
+	// Лимит по IP до проверки JWT, 0 — выключен
+	RateLimitIPRPS   int
+	RateLimitIPBurst int
+	// Множители лимита по ролям
+	RateLimitTiers map[string]float64
+	// Прокси, которым разрешено передавать адрес клиента в X-Forwarded-For
+	TrustedProxies []*net.IPNet
+
+	// Квоты запросов на пользователя, 0 — без ограничения
+	QuotaDaily   int64
+	QuotaMonthly int64
+	// memory (со снимком в UsageFile) или redis
+	UsageStore     string
+	UsageFile      string
+	UsageKeyPrefix string
+	// Ключи API с собственными квотами, читаются из APIKeysFile
+	APIKeysFile string
+	APIKeys     []middleware.APIKey
+	// Как часто сохранять снимок счётчиков в UsageFile
+	UsageSnapshotInterval time.Duration
+
+	// Как часто проверять изменения ENV_FILE
+	ConfigWatchInterval time.Duration
+}
+
+// Load собирает конфигурацию из переменных окружения.
+// В отличие от прежних getEnv*, некорректное значение — ошибка, а не тихий откат к значению по умолчанию:
+// иначе опечатка при перезагрузке незаметно сбросила бы настройку.
+func Load(env map[string]string) (*Config, error) {
+	p := &parser{env: env}
+
+	cfg := &Config{
+		Port:                    p.string("PORT", "8080"),
+		RoutesFile:              p.string("ROUTES_FILE", ""),
+		JWKSURL:                 p.string("JWKS_URL", "http://localhost:3001/.well-known/jwks.json"),
+		JWKSRefreshInterval:     p.duration("JWKS_REFRESH_INTERVAL", 5*time.Minute),
+		RevocationsURL:          p.string("REVOCATIONS_URL", "http://localhost:3001/internal/revocations"),
+		InternalIdentityKeyFile: p.string("INTERNAL_IDENTITY_KEY_FILE", ""),
+		UserServiceURL:          p.string("USER_SERVICE_URL", "http://localhost:3001"),
+		OrderServiceURL:         p.string("ORDER_SERVICE_URL", "http://localhost:3002"),
+		RateLimitStore:          p.string("RATE_LIMIT_STORE", "memory"),
+		RateLimitKeyPrefix:      p.string("RATE_LIMIT_KEY_PREFIX", "gateway:ratelimit:"),
+		RedisURL:                p.string("REDIS_URL", "redis://localhost:6379/0"),
+		RateLimitRPS:            p.int("RATE_LIMIT_RPS", 100),
+		RateLimitBurst:          p.int("RATE_LIMIT_BURST", 200),
+		RateLimitIPRPS:          p.int("RATE_LIMIT_IP_RPS", 200),
+		RateLimitIPBurst:        p.int("RATE_LIMIT_IP_BURST", 400),
+		RateLimitTiers:          p.tiers("RATE_LIMIT_TIERS", "admin:10"),
+		QuotaDaily:              int64(p.int("QUOTA_DAILY", 0)),
+		QuotaMonthly:            int64(p.int("QUOTA_MONTHLY", 0)),
+		UsageStore:              p.string("USAGE_STORE", "memory"),
+		UsageFile:               p.string("USAGE_FILE", "usage.json"),
+		UsageKeyPrefix:          p.string("USAGE_KEY_PREFIX", "gateway:usage:"),
+		APIKeysFile:             p.string("API_KEYS_FILE", ""),
+		UsageSnapshotInterval:   p.duration("USAGE_SNAPSHOT_INTERVAL", 30*time.Second),
+		ConfigWatchInterval:     p.duration("CONFIG_WATCH_INTERVAL", 5*time.Second),
+	}
+
+	apiKeys, err := loadAPIKeys(cfg.APIKeysFile)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("API_KEYS_FILE: %w", err))
+	}
+	cfg.APIKeys = apiKeys
+
+	trustedProxies, err := middleware.ParseTrustedProxies(p.string("TRUSTED_PROXIES", ""))
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("TRUSTED_PROXIES: %w", err))
+	}
+	cfg.TrustedProxies = trustedProxies
+
+	// Несколько экземпляров сервиса указываются через запятую
+	userServiceInstances, err := proxy.ParseInstances(cfg.UserServiceURL)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("USER_SERVICE_URL: %w", err))
+	}
+	orderServiceInstances, err := proxy.ParseInstances(cfg.OrderServiceURL)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("ORDER_SERVICE_URL: %w", err))
+	}
+	lbStrategy := p.string("LB_STRATEGY", proxy.StrategyRoundRobin)
+
+	cfg.Proxy = proxy.Config{
+		UserService: proxy.UpstreamConfig{
+			Instances: userServiceInstances,
+			Strategy:  p.string("USER_SERVICE_LB_STRATEGY", lbStrategy),
+		},
+		OrderService: proxy.UpstreamConfig{
+			Instances: orderServiceInstances,
+			Strategy:  p.string("ORDER_SERVICE_LB_STRATEGY", lbStrategy),
+		},
+		DialTimeout:           p.duration("UPSTREAM_DIAL_TIMEOUT", 5*time.Second),
+		ResponseHeaderTimeout: p.duration("UPSTREAM_RESPONSE_TIMEOUT", 30*time.Second),
+		Breaker: proxy.BreakerConfig{
+			FailureRatio:     p.float("CB_FAILURE_RATIO", 0.5),
+			MinRequests:      p.int("CB_MIN_REQUESTS", 10),
+			Window:           p.duration("CB_WINDOW", 60*time.Second),
+			OpenTimeout:      p.duration("CB_OPEN_TIMEOUT", 30*time.Second),
+			HalfOpenRequests: p.int("CB_HALF_OPEN_REQUESTS", 1),
+		},
+		Retry: proxy.RetryPolicy{
+			MaxAttempts: p.int("RETRY_MAX_ATTEMPTS", 3),
+			BaseDelay:   p.duration("RETRY_BASE_DELAY", 100*time.Millisecond),
+			MaxDelay:    p.duration("RETRY_MAX_DELAY", 2*time.Second),
+		},
+		HealthCheck: proxy.HealthCheckConfig{
+			Path:               p.string("HEALTH_CHECK_PATH", "/health"),
+			Interval:           p.duration("HEALTH_CHECK_INTERVAL", 10*time.Second),
+			Timeout:            p.duration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			UnhealthyThreshold: p.int("HEALTH_CHECK_UNHEALTHY_THRESHOLD", 3),
+			HealthyThreshold:   p.int("HEALTH_CHECK_HEALTHY_THRESHOLD", 2),
+		},
+	}
+
+	for _, strategy := range []string{cfg.Proxy.UserService.Strategy, cfg.Proxy.OrderService.Strategy} {
+		if _, err := proxy.NewBalancer(strategy); err != nil {
+			p.errs = append(p.errs, err)
+		}
+	}
+	if cfg.RateLimitStore != "memory" && cfg.RateLimitStore != "redis" {
+		p.errs = append(p.errs, fmt.Errorf("RATE_LIMIT_STORE must be memory or redis, got %q", cfg.RateLimitStore))
+	}
+	if cfg.UsageStore != "memory" && cfg.UsageStore != "redis" {
+		p.errs = append(p.errs, fmt.Errorf("USAGE_STORE must be memory or redis, got %q", cfg.UsageStore))
+	}
+	if cfg.QuotaDaily < 0 || cfg.QuotaMonthly < 0 {
+		p.errs = append(p.errs, errors.New("QUOTA_DAILY and QUOTA_MONTHLY cannot be negative"))
+	}
+	if cfg.UsageSnapshotInterval <= 0 || cfg.ConfigWatchInterval <= 0 {
+		p.errs = append(p.errs, errors.New("USAGE_SNAPSHOT_INTERVAL and CONFIG_WATCH_INTERVAL must be greater than 0"))
+	}
+	if cfg.RateLimitRPS <= 0 || cfg.RateLimitBurst <= 0 {
+		p.errs = append(p.errs, errors.New("RATE_LIMIT_RPS and RATE_LIMIT_BURST must be greater than 0"))
+	}
+	if cfg.RateLimitIPRPS < 0 || (cfg.RateLimitIPRPS > 0 && cfg.RateLimitIPBurst <= 0) {
+		p.errs = append(p.errs, errors.New("RATE_LIMIT_IP_RPS cannot be negative and needs RATE_LIMIT_IP_BURST greater than 0"))
+	}
+
+	if err := errors.Join(p.errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+type parser struct {
+	env  map[string]string
+	errs []error
+}
+
+func (p *parser) string(key, defaultValue string) string {
+	value := p.env[key]
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// tiers разбирает список вида "admin:10,user:1"
+func (p *parser) tiers(key, defaultValue string) map[string]float64 {
+	tiers := make(map[string]float64)
+	for _, part := range strings.Split(p.string(key, defaultValue), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		role, rawMultiplier, ok := strings.Cut(part, ":")
+		multiplier, err := strconv.ParseFloat(strings.TrimSpace(rawMultiplier), 64)
+		if !ok || err != nil || multiplier <= 0 {
+			p.errs = append(p.errs, fmt.Errorf("%s: invalid tier %q, expected role:multiplier", key, part))
+			continue
+		}
+		tiers[strings.TrimSpace(role)] = multiplier
+	}
+	return tiers
+}
+
+func (p *parser) int(key string, defaultValue int) int {
+	value := p.env[key]
+	if value == "" {
+		return defaultValue
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("%s: invalid integer %q", key, value))
+		return defaultValue
+	}
+	return intValue
+}
+
+func (p *parser) float(key string, defaultValue float64) float64 {
+	value := p.env[key]
+	if value == "" {
+		return defaultValue
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("%s: invalid number %q", key, value))
+		return defaultValue
+	}
+	return floatValue
+}
+
+func (p *parser) duration(key string, defaultValue time.Duration) time.Duration {
+	value := p.env[key]
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		p.errs = append(p.errs, fmt.Errorf("%s: invalid duration %q", key, value))
+		return defaultValue
+	}
+	return duration
+}
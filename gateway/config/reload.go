@@ -0,0 +1,252 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Source читает конфигурацию из .env файла поверх окружения процесса.
+// Как и godotenv.Load, значения из окружения процесса имеют приоритет над файлом.
+type Source struct {
+	envFile string
+	baseEnv map[string]string
+}
+
+func NewSource(envFile string) *Source {
+	baseEnv := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			baseEnv[key] = value
+		}
+	}
+	return &Source{envFile: envFile, baseEnv: baseEnv}
+}
+
+func (s *Source) Read() (map[string]string, error) {
+	env := make(map[string]string)
+
+	fileEnv, err := godotenv.Read(s.envFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", s.envFile, err)
+	}
+	for key, value := range fileEnv {
+		env[key] = value
+	}
+	for key, value := range s.baseEnv {
+		env[key] = value
+	}
+	return env, nil
+}
+
+func (s *Source) modTime() time.Time {
+	info, err := os.Stat(s.envFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Applier проверяет новую конфигурацию и возвращает функцию, применяющую её.
+// Применение не должно завершаться ошибкой: всё, что может не пройти, проверяется заранее.
+type Applier func(next *Config) (commit func(), err error)
+
+type ReloadStatus struct {
+	Version       int        `json:"version"`
+	LoadedAt      time.Time  `json:"loadedAt"`
+	LastAttemptAt *time.Time `json:"lastAttemptAt,omitempty"`
+	LastTrigger   string     `json:"lastTrigger,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	// Изменённые параметры, которые вступят в силу только после перезапуска
+	RestartRequired []string `json:"restartRequired,omitempty"`
+}
+
+type Manager struct {
+	source *Source
+	// Конфигурация, с которой процесс был запущен
+	initial  *Config
+	current  atomic.Pointer[Config]
+	appliers []Applier
+
+	mu     sync.Mutex
+	status ReloadStatus
+}
+
+func NewManager(source *Source) (*Manager, error) {
+	env, err := source.Read()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Load(env)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		source:  source,
+		initial: cfg,
+		status:  ReloadStatus{Version: 1, LoadedAt: time.Now()},
+	}
+	m.current.Store(cfg)
+	return m, nil
+}
+
+func (m *Manager) Current() *Config {
+	return m.current.Load()
+}
+
+// OnReload регистрирует компонент, который подхватывает новую конфигурацию
+func (m *Manager) OnReload(applier Applier) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.appliers = append(m.appliers, applier)
+}
+
+func (m *Manager) Status() ReloadStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// Reload перечитывает конфигурацию. При ошибке остаётся действующая конфигурация,
+// а причина отказа сохраняется в статусе.
+func (m *Manager) Reload(trigger string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.status.LastAttemptAt = &now
+	m.status.LastTrigger = trigger
+
+	err := m.reload()
+	if err != nil {
+		m.status.LastError = err.Error()
+		log.Printf("Config reload (%s) rejected, keeping version %d: %v", trigger, m.status.Version, err)
+		return err
+	}
+
+	m.status.LastError = ""
+	log.Printf("Config reloaded (%s), version %d", trigger, m.status.Version)
+	return nil
+}
+
+func (m *Manager) reload() error {
+	env, err := m.source.Read()
+	if err != nil {
+		return err
+	}
+	next, err := Load(env)
+	if err != nil {
+		return err
+	}
+
+	commits := make([]func(), 0, len(m.appliers))
+	for _, applier := range m.appliers {
+		commit, err := applier(next)
+		if err != nil {
+			return err
+		}
+		commits = append(commits, commit)
+	}
+	for _, commit := range commits {
+		commit()
+	}
+
+	restartRequired := restartOnlyChanges(m.initial, next)
+	for _, field := range restartRequired {
+		log.Printf("Config: %s changed, restart required to apply", field)
+	}
+
+	m.current.Store(next)
+	m.status.Version++
+	m.status.LoadedAt = time.Now()
+	m.status.RestartRequired = restartRequired
+	return nil
+}
+
+// Watch перезагружает конфигурацию по SIGHUP и при изменении .env файла
+func (m *Manager) Watch(ctx context.Context, interval time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	lastModTime := m.source.modTime()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			m.Reload("SIGHUP")
+		case <-ticks:
+			modTime := m.source.modTime()
+			if !modTime.Equal(lastModTime) {
+				lastModTime = modTime
+				m.Reload(m.source.envFile + " changed")
+			}
+		}
+	}
+}
+
+// restartOnlyChanges перечисляет изменения, которые нельзя применить на лету
+func restartOnlyChanges(prev, next *Config) []string {
+	var changed []string
+	if prev.Port != next.Port {
+		changed = append(changed, "PORT")
+	}
+	if prev.RoutesFile != next.RoutesFile {
+		changed = append(changed, "ROUTES_FILE")
+	}
+	if prev.JWKSURL != next.JWKSURL || prev.JWKSRefreshInterval != next.JWKSRefreshInterval {
+		changed = append(changed, "JWKS_*")
+	}
+	if prev.RevocationsURL != next.RevocationsURL {
+		changed = append(changed, "REVOCATIONS_URL")
+	}
+	if prev.InternalIdentityKeyFile != next.InternalIdentityKeyFile {
+		changed = append(changed, "INTERNAL_IDENTITY_KEY_FILE")
+	}
+	if prev.RateLimitStore != next.RateLimitStore || prev.RedisURL != next.RedisURL ||
+		prev.RateLimitKeyPrefix != next.RateLimitKeyPrefix {
+		changed = append(changed, "RATE_LIMIT_STORE / REDIS_URL")
+	}
+	if prev.UsageStore != next.UsageStore || prev.UsageFile != next.UsageFile ||
+		prev.UsageKeyPrefix != next.UsageKeyPrefix || prev.UsageSnapshotInterval != next.UsageSnapshotInterval {
+		changed = append(changed, "USAGE_STORE / USAGE_FILE")
+	}
+	if prev.ConfigWatchInterval != next.ConfigWatchInterval {
+		changed = append(changed, "CONFIG_WATCH_INTERVAL")
+	}
+	if prev.Proxy.DialTimeout != next.Proxy.DialTimeout ||
+		prev.Proxy.ResponseHeaderTimeout != next.Proxy.ResponseHeaderTimeout {
+		changed = append(changed, "UPSTREAM_*_TIMEOUT")
+	}
+	if !reflect.DeepEqual(prev.Proxy.Breaker, next.Proxy.Breaker) {
+		changed = append(changed, "CB_*")
+	}
+	if !reflect.DeepEqual(prev.Proxy.Retry, next.Proxy.Retry) {
+		changed = append(changed, "RETRY_*")
+	}
+	if !reflect.DeepEqual(prev.Proxy.HealthCheck, next.Proxy.HealthCheck) {
+		changed = append(changed, "HEALTH_CHECK_*")
+	}
+	return changed
+}
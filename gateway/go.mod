@@ -1,11 +1,23 @@
 module gateway
 
-go 1.21
+go 1.25.3
 
 require (
+	github.com/ChrolloLucii/control-system/shared v0.0.0-00010101000000-000000000000
+	github.com/alicebob/miniredis/v2 v2.39.0
 	github.com/go-chi/chi/v5 v5.0.11
-	github.com/golang-jwt/jwt/v5 v5.2.0
+	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
-	golang.org/x/time v0.5.0
+	github.com/redis/go-redis/v9 v9.9.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	golang.org/x/sync v0.22.0 // indirect
+)
+
+replace github.com/ChrolloLucii/control-system/shared => ../shared
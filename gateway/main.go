@@ -1,103 +1,248 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"log"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"gateway/config"
 	"gateway/middleware"
 	"gateway/proxy"
+	"gateway/routes"
 
+	"github.com/ChrolloLucii/control-system/shared/jwks"
+	"github.com/ChrolloLucii/control-system/shared/revocation"
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
-	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	// Конфиг читается из .env поверх окружения и перечитывается по SIGHUP или при изменении файла
+	configSource := config.NewSource(getEnv("ENV_FILE", ".env"))
+	configManager, err := config.NewManager(configSource)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	cfg := configManager.Current()
+
+	reverseProxy, err := proxy.NewReverseProxy(cfg.Proxy)
+	if err != nil {
+		log.Fatalf("Invalid proxy configuration: %v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	reverseProxy.StartHealthChecks(ctx)
+	var redisClient *redis.Client
+	if cfg.RateLimitStore == "redis" || cfg.UsageStore == "redis" {
+		redisClient, err = newRedisClient(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Invalid REDIS_URL: %v", err)
+		}
 	}
 
-	// Конфиг
-	port := getEnv("PORT", "8080")
-	jwtSecret := getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production-12345")
-	userServiceURL := getEnv("USER_SERVICE_URL", "http://localhost:3001")
-	orderServiceURL := getEnv("ORDER_SERVICE_URL", "http://localhost:3002")
-	rateLimitRPS := getEnvInt("RATE_LIMIT_RPS", 100)
-	rateLimitBurst := getEnvInt("RATE_LIMIT_BURST", 200)
+	// Общие счётчики в Redis нужны, когда реплик шлюза несколько
+	var rateLimitStore middleware.RateLimitStore = middleware.NewMemoryRateLimitStore()
+	if cfg.RateLimitStore == "redis" {
+		rateLimitStore = middleware.NewRedisRateLimitStore(redisClient, cfg.RateLimitKeyPrefix)
+	}
+	rateLimiter := middleware.NewRateLimiter(rateLimitStore, cfg.RateLimitRPS, cfg.RateLimitBurst)
+	rateLimiter.SetIPLimit(cfg.RateLimitIPRPS, cfg.RateLimitIPBurst)
+	rateLimiter.SetTiers(cfg.RateLimitTiers)
+	rateLimiter.SetTrustedProxies(cfg.TrustedProxies)
+
+	var usageStore middleware.UsageStore
+	usageSaved := make(chan struct{})
+	if cfg.UsageStore == "redis" {
+		usageStore = middleware.NewRedisUsageStore(redisClient, cfg.UsageKeyPrefix)
+		close(usageSaved)
+	} else {
+		memoryUsageStore, err := middleware.NewMemoryUsageStore(cfg.UsageFile)
+		if err != nil {
+			log.Fatalf("Failed to load usage: %v", err)
+		}
+		go func() {
+			memoryUsageStore.Run(ctx, cfg.UsageSnapshotInterval)
+			close(usageSaved)
+		}()
+		usageStore = memoryUsageStore
+	}
+	quota := middleware.NewQuota(usageStore, middleware.QuotaLimits{Daily: cfg.QuotaDaily, Monthly: cfg.QuotaMonthly})
+	quota.SetTiers(cfg.RateLimitTiers)
+	quota.SetAPIKeys(cfg.APIKeys)
+	jwksClient := jwks.NewClient(cfg.JWKSURL, cfg.JWKSRefreshInterval)
+	if err := jwksClient.Refresh(ctx); err != nil {
+		// Ключи будут загружены при первом запросе с токеном
+		log.Printf("JWKS is not available yet: %v", err)
+	}
+	// Отзыв токенов доходит до шлюза через long polling к user-service
+	revocations := revocation.NewCache(cfg.RevocationsURL)
+	if err := revocations.Sync(ctx); err != nil {
+		log.Printf("Token revocation list is not available yet: %v", err)
+	}
+	go revocations.Run(ctx)
+	identitySigner, err := middleware.NewIdentitySigner(cfg.InternalIdentityKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load internal identity key: %v", err)
+	}
 
-	reverseProxy := proxy.NewReverseProxy(userServiceURL, orderServiceURL)
-	rateLimiter := middleware.NewRateLimiter(rateLimitRPS, rateLimitBurst)
+	// Что можно поменять без перезапуска: лимиты, квоты и адреса upstream'ов
+	configManager.OnReload(func(next *config.Config) (func(), error) {
+		return reverseProxy.PrepareUpdate(next.Proxy)
+	})
+	configManager.OnReload(func(next *config.Config) (func(), error) {
+		return func() {
+			rateLimiter.SetLimit(next.RateLimitRPS, next.RateLimitBurst)
+			rateLimiter.SetIPLimit(next.RateLimitIPRPS, next.RateLimitIPBurst)
+			rateLimiter.SetTiers(next.RateLimitTiers)
+			rateLimiter.SetTrustedProxies(next.TrustedProxies)
+			quota.SetLimits(middleware.QuotaLimits{Daily: next.QuotaDaily, Monthly: next.QuotaMonthly})
+			quota.SetTiers(next.RateLimitTiers)
+			quota.SetAPIKeys(next.APIKeys)
+		}, nil
+	})
+	go configManager.Watch(ctx, cfg.ConfigWatchInterval)
 
 	r := chi.NewRouter()
 
 	// Глобальные middleware
 	r.Use(chiMiddleware.Logger)
 	r.Use(chiMiddleware.Recoverer)
+	r.Use(middleware.StripInternalHeaders)
 	r.Use(middleware.RequestIDMiddleware)
 	r.Use(middleware.CORSMiddleware)
-	r.Use(rateLimiter.Middleware)
 
 	// Health check
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		upstreams := reverseProxy.Status()
+
+		// Шлюз жив, даже если какой-то экземпляр исключён health check'ом или отключён предохранителем
+		status := "ok"
+		for _, upstream := range upstreams {
+			for _, instance := range upstream.Instances {
+				if !instance.Healthy || instance.Breaker.State != proxy.StateClosed.String() {
+					status = "degraded"
+				}
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok","service":"gateway"}`))
-	})
-
-	r.Route("/api/v1/users", func(r chi.Router) {
-		// Публичные маршруты
-		r.Group(func(r chi.Router) {
-			r.Post("/register", reverseProxy.ProxyToUserService)
-			r.Post("/login", reverseProxy.ProxyToUserService)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":    status,
+			"service":   "gateway",
+			"upstreams": upstreams,
 		})
+	})
 
-		// Защищённые маршруты
-		r.Group(func(r chi.Router) {
-			r.Use(middleware.JWTAuthMiddleware(jwtSecret))
-			r.Get("/profile", reverseProxy.ProxyToUserService)
-			r.Put("/profile", reverseProxy.ProxyToUserService)
-			r.Get("/", reverseProxy.ProxyToUserService) // Список пользователей
-		})
+	// Ключ, которым сервисы проверяют X-Internal-Identity
+	r.Get("/.well-known/gateway-jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		set, err := identitySigner.JWKS()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(set)
 	})
 
-	// Order Service routes
-	r.Route("/api/v1/orders", func(r chi.Router) {
-		r.Use(middleware.JWTAuthMiddleware(jwtSecret))
-		r.Post("/", reverseProxy.ProxyToOrderService)
-		r.Get("/", reverseProxy.ProxyToOrderService)
-		r.Get("/{id}", reverseProxy.ProxyToOrderService)
-		r.Put("/{id}/status", reverseProxy.ProxyToOrderService)
-		r.Delete("/{id}", reverseProxy.ProxyToOrderService)
+	// Управление конфигурацией
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(rateLimiter.IPLimit)
+		r.Use(middleware.JWTAuthMiddleware(jwksClient, revocations))
+		r.Use(middleware.AdminMiddleware)
+		r.Use(rateLimiter.Middleware)
+		r.Get("/config", configManager.StatusHandler)
+		r.Post("/config/reload", configManager.ReloadHandler)
+		r.Get("/usage", quota.UsageHandler)
 	})
 
-	log.Printf("Gateway starting on port %s", port)
-	log.Printf("User Service: %s", userServiceURL)
-	log.Printf("Order Service: %s", orderServiceURL)
-	log.Printf("Rate Limit: %d RPS, Burst: %d", rateLimitRPS, rateLimitBurst)
+	// Маршруты к микросервисам описываются таблицей (ROUTES_FILE), по умолчанию — встроенная
+	routeTable, err := routes.Load(cfg.RoutesFile)
+	if err != nil {
+		log.Fatalf("Failed to load route table: %v", err)
+	}
+	if err := routeTable.Validate(reverseProxy.Upstreams()); err != nil {
+		log.Fatalf("Invalid route table:\n%v", err)
+	}
+
+	routeTable.Mount(r, routes.Dependencies{
+		Upstream:    reverseProxy.Handler,
+		IPRateLimit: rateLimiter.IPLimit,
+		JWTAuth:     middleware.JWTAuthMiddleware(jwksClient, revocations),
+		Admin:       middleware.AdminMiddleware,
+		Quota:       quota.Middleware,
+		RateLimit: func(group string, limit *routes.RateLimit) routes.Middleware {
+			if limit == nil {
+				return rateLimiter.Middleware
+			}
+			return rateLimiter.Limit(group, &middleware.Limit{RPS: limit.RPS, Burst: limit.Burst})
+		},
+		Timeout: func(timeout time.Duration) routes.Middleware {
+			return middleware.Timeout(timeout)
+		},
+		Identity: identitySigner.Middleware,
+	})
 
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	log.Printf("Gateway starting on port %s", cfg.Port)
+	log.Printf("User Service: %s", cfg.UserServiceURL)
+	log.Printf("Order Service: %s", cfg.OrderServiceURL)
+	log.Printf("Rate Limit: %d RPS, Burst: %d, store: %s", cfg.RateLimitRPS, cfg.RateLimitBurst, cfg.RateLimitStore)
+	log.Printf("Rate Limit per IP before auth: %d RPS, Burst: %d", cfg.RateLimitIPRPS, cfg.RateLimitIPBurst)
+	log.Printf("Circuit Breaker: failure ratio %.2f, min requests %d, open timeout %s",
+		cfg.Proxy.Breaker.FailureRatio, cfg.Proxy.Breaker.MinRequests, cfg.Proxy.Breaker.OpenTimeout)
+
+	log.Printf("Quota: %d per day, %d per month, store: %s", cfg.QuotaDaily, cfg.QuotaMonthly, cfg.UsageStore)
+	log.Printf("API keys: %d", len(cfg.APIKeys))
+
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// При остановке дожидаемся текущих запросов и сохраняем счётчики использования
+	<-ctx.Done()
+	log.Println("Gateway shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Graceful shutdown failed: %v", err)
 	}
+	<-usageSaved
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// newRedisClient подключается к Redis, общему для лимитов и учёта использования
+func newRedisClient(redisURL string) (*redis.Client, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
 	}
-	return value
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		// Не падаем: лимиты и квоты пропускают запросы, пока Redis недоступен
+		log.Printf("Redis is not reachable at startup: %v", err)
+	}
+
+	return client, nil
 }
 
-func getEnvInt(key string, defaultValue int) int {
+func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
 		return defaultValue
 	}
-	intValue, err := strconv.Atoi(value)
-	if err != nil {
-		return defaultValue
-	}
-	return intValue
+	return value
 }
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Заголовок, которым внутренние команды и скрипты передают ключ API
+const APIKeyHeader = "X-API-Key"
+
+// Квоты ключа считаются отдельно от пользователей: в хранилище его счётчики лежат под apikey:<id>
+const apiKeySubjectPrefix = "apikey:"
+
+// APIKey — ключ клиента API со своими квотами. Сам ключ в конфигурации не хранится, только его SHA-256.
+type APIKey struct {
+	ID     string
+	SHA256 string
+	Limits QuotaLimits
+}
+
+// HashAPIKey возвращает SHA-256 ключа в hex, как он записан в API_KEYS_FILE
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
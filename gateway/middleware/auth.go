@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/ChrolloLucii/control-system/shared/jwks"
+	"github.com/ChrolloLucii/control-system/shared/revocation"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
@@ -31,7 +34,9 @@ type Error struct {
 	Message string `json:"message"`
 }
 
-func JWTAuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
+// JWTAuthMiddleware проверяет токены, выданные user-service, по его публичным ключам (JWKS)
+// и по списку отзыва
+func JWTAuthMiddleware(keys *jwks.Client, revocations *revocation.Cache) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
@@ -49,25 +54,54 @@ func JWTAuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 			tokenString := parts[1]
 			claims := &Claims{}
 
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				return []byte(jwtSecret), nil
-			})
+			token, err := jwt.ParseWithClaims(tokenString, claims, keys.KeyfuncContext(r.Context()), jwt.WithValidMethods(jwks.ValidMethods))
 
 			if err != nil || !token.Valid {
 				respondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "invalid or expired token")
 				return
 			}
 
-			// Добавляем токен в заголовок для проксирования
-			r.Header.Set("X-User-ID", claims.UserID.String())
-			r.Header.Set("X-User-Email", claims.Email)
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			if revocations.IsRevoked(claims.ID, claims.UserID.String(), issuedAt) {
+				respondWithError(w, http.StatusUnauthorized, "TOKEN_REVOKED", "token has been revoked")
+				return
+			}
 
+			// Личность передаётся сервисам подписанным подтверждением, см. IdentitySigner
 			ctx := context.WithValue(r.Context(), UserContextKey, claims)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value(UserContextKey).(*Claims)
+		if !ok {
+			respondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "user not authenticated")
+			return
+		}
+
+		hasAdminRole := false
+		for _, role := range claims.Roles {
+			if role == "admin" {
+				hasAdminRole = true
+				break
+			}
+		}
+
+		if !hasAdminRole {
+			respondWithError(w, http.StatusForbidden, "FORBIDDEN", "admin access required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func respondWithError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ChrolloLucii/control-system/shared/identity"
+	"github.com/ChrolloLucii/control-system/shared/jwks"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Заголовки с этими префиксами выставляет только шлюз
+var reservedHeaderPrefixes = []string{"X-User-", "X-Internal-"}
+
+// StripInternalHeaders удаляет зарезервированные заголовки из входящих запросов,
+// чтобы клиент не мог выдать себя за шлюз, в том числе на публичных маршрутах
+func StripInternalHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name := range r.Header {
+			for _, prefix := range reservedHeaderPrefixes {
+				if strings.HasPrefix(http.CanonicalHeaderKey(name), prefix) {
+					r.Header.Del(name)
+					break
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IdentitySigner подписывает подтверждения личности для сервисов (ES256).
+// Публичный ключ шлюз отдаёт на /.well-known/gateway-jwks.json.
+type IdentitySigner struct {
+	key *ecdsa.PrivateKey
+	kid string
+}
+
+// NewIdentitySigner загружает ключ из PEM (PKCS#8, P-256). Без файла ключ создаётся при старте:
+// так можно только с одной репликой шлюза — сервисы получат ключ той реплики, к которой обратятся.
+func NewIdentitySigner(keyFile string) (*IdentitySigner, error) {
+	var key *ecdsa.PrivateKey
+	if keyFile == "" {
+		log.Printf("INTERNAL_IDENTITY_KEY_FILE is not set, using an ephemeral identity key")
+		generated, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key = generated
+	} else {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no PEM data", keyFile)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keyFile, err)
+		}
+		ecKey, ok := parsed.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s: expected a P-256 EC key", keyFile)
+		}
+		key = ecKey
+	}
+
+	// kid выводится из публичного ключа, поэтому у реплик с общим ключом он совпадает
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(publicKey)
+	return &IdentitySigner{key: key, kid: "gw-" + hex.EncodeToString(sum[:8])}, nil
+}
+
+func (s *IdentitySigner) JWKS() (jwks.Set, error) {
+	key, err := jwks.NewKey(s.kid, &s.key.PublicKey)
+	if err != nil {
+		return jwks.Set{}, err
+	}
+	return jwks.Set{Keys: []jwks.Key{key}}, nil
+}
+
+// Middleware передаёт сервису личность пользователя, проверенную JWTAuthMiddleware,
+// в виде короткоживущего подтверждения, подписанного шлюзом
+func (s *IdentitySigner) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value(UserContextKey).(*Claims)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		assertion, err := s.sign(claims)
+		if err != nil {
+			log.Printf("Failed to sign internal identity: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "failed to forward identity")
+			return
+		}
+		r.Header.Set(identity.Header, assertion)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *IdentitySigner) sign(user *Claims) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID: user.UserID,
+		Email:  user.Email,
+		Roles:  user.Roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			// jti исходного токена, чтобы связать запрос с сессией пользователя
+			ID:        user.ID,
+			Subject:   user.UserID.String(),
+			Issuer:    identity.Issuer,
+			Audience:  jwt.ClaimStrings{identity.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(identity.TTL)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = s.kid
+	return token.SignedString(s.key)
+}
@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Quota ограничивает число запросов пользователя или ключа API за сутки и за календарный
+// месяц (UTC) и ведёт учёт использования для отчётов
+type Quota struct {
+	store  UsageStore
+	mu     sync.RWMutex
+	limits QuotaLimits
+	// Множители квот по ролям — те же уровни, что и у rate limiting
+	tiers map[string]float64
+	// Ключи API по SHA-256
+	apiKeys map[string]APIKey
+}
+
+func NewQuota(store UsageStore, limits QuotaLimits) *Quota {
+	return &Quota{
+		store:   store,
+		limits:  limits,
+		tiers:   make(map[string]float64),
+		apiKeys: make(map[string]APIKey),
+	}
+}
+
+func (q *Quota) SetLimits(limits QuotaLimits) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.limits = limits
+}
+
+func (q *Quota) SetTiers(tiers map[string]float64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.tiers = tiers
+}
+
+// SetAPIKeys заменяет набор ключей API; счётчики ключей с тем же id сохраняются
+func (q *Quota) SetAPIKeys(keys []APIKey) {
+	apiKeys := make(map[string]APIKey, len(keys))
+	for _, key := range keys {
+		apiKeys[key.SHA256] = key
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.apiKeys = apiKeys
+}
+
+// Middleware учитывает запрос по ключу API из X-API-Key, если он передан, иначе — по пользователю
+// из JWT, поэтому стоит после JWTAuthMiddleware. Анонимные запросы без ключа пропускаются без учёта:
+// их сдерживает только rate limiting.
+func (q *Quota) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, limits, ok := q.resolve(r)
+		if !ok {
+			if r.Header.Get(APIKeyHeader) != "" {
+				respondWithError(w, http.StatusUnauthorized, "INVALID_API_KEY", "unknown API key")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Ключ нужен только шлюзу, сервисам его не передаём
+		r.Header.Del(APIKeyHeader)
+
+		now := time.Now().UTC()
+		usage, allowed, err := q.store.Consume(r.Context(), subject, usagePeriods(now), limits)
+		if err != nil {
+			// Как и у rate limiting, сбой хранилища не должен останавливать трафик
+			log.Printf("Usage store error, request allowed: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if limits.Daily > 0 {
+			w.Header().Set("X-Quota-Daily-Limit", strconv.FormatInt(limits.Daily, 10))
+			w.Header().Set("X-Quota-Daily-Remaining", strconv.FormatInt(max(limits.Daily-usage.Day, 0), 10))
+		}
+		if limits.Monthly > 0 {
+			w.Header().Set("X-Quota-Monthly-Limit", strconv.FormatInt(limits.Monthly, 10))
+			w.Header().Set("X-Quota-Monthly-Remaining", strconv.FormatInt(max(limits.Monthly-usage.Month, 0), 10))
+		}
+
+		if !allowed {
+			// Повторять имеет смысл только после начала следующего периода
+			resetAt, message := nextDay(now), "daily request quota exceeded"
+			if limits.Monthly > 0 && usage.Month >= limits.Monthly {
+				resetAt, message = nextMonth(now), "monthly request quota exceeded"
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(resetAt.Sub(now))))
+			respondWithError(w, http.StatusTooManyRequests, "QUOTA_EXCEEDED", message)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// resolve возвращает, чьи счётчики учитывают запрос, и действующие для них лимиты.
+// Ключ API важнее пользователя: скрипт команды расходует квоту ключа, а не личную.
+func (q *Quota) resolve(r *http.Request) (string, QuotaLimits, bool) {
+	if rawKey := r.Header.Get(APIKeyHeader); rawKey != "" {
+		q.mu.RLock()
+		key, exists := q.apiKeys[HashAPIKey(rawKey)]
+		q.mu.RUnlock()
+		if !exists {
+			return "", QuotaLimits{}, false
+		}
+		return apiKeySubjectPrefix + key.ID, key.Limits, true
+	}
+
+	claims, ok := r.Context().Value(UserContextKey).(*Claims)
+	if !ok {
+		return "", QuotaLimits{}, false
+	}
+	return claims.UserID.String(), q.limitsFor(claims.Roles), true
+}
+
+func (q *Quota) limitsFor(roles []string) QuotaLimits {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	multiplier := tierMultiplier(roles, q.tiers)
+	scale := func(limit int64) int64 {
+		if limit == 0 {
+			return 0
+		}
+		return int64(math.Max(1, math.Round(float64(limit)*multiplier)))
+	}
+	return QuotaLimits{Daily: scale(q.limits.Daily), Monthly: scale(q.limits.Monthly)}
+}
+
+type usagePeriodView struct {
+	Period   string `json:"period"`
+	Requests int64  `json:"requests"`
+	Limit    int64  `json:"limit,omitempty"`
+	ResetAt  string `json:"resetAt"`
+}
+
+// UsageHandler отдаёт использование пользователя (userId) или ключа API (apiKey — его id):
+// текущие сутки и месяц, а также историю по дням и месяцам. Лимиты пользователя указаны базовые:
+// множители зависят от ролей, которые известны только из токена пользователя.
+func (q *Quota) UsageHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var subject string
+	report := map[string]interface{}{}
+
+	q.mu.RLock()
+	limits := q.limits
+	if keyID := query.Get("apiKey"); keyID != "" {
+		subject = apiKeySubjectPrefix + keyID
+		report["apiKey"] = keyID
+		// Удалённый ключ отдаёт историю без лимитов
+		limits = QuotaLimits{}
+		for _, key := range q.apiKeys {
+			if key.ID == keyID {
+				limits = key.Limits
+			}
+		}
+	}
+	q.mu.RUnlock()
+
+	if subject == "" {
+		userID, err := uuid.Parse(query.Get("userId"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "VALIDATION_ERROR", "userId query parameter must be a valid UUID, or apiKey must be set")
+			return
+		}
+		subject = userID.String()
+		report["userId"] = userID
+	}
+
+	usage, err := q.store.Usage(r.Context(), subject)
+	if err != nil {
+		log.Printf("Failed to read usage for %s: %v", subject, err)
+		respondWithError(w, http.StatusServiceUnavailable, "USAGE_UNAVAILABLE", "usage store is unavailable")
+		return
+	}
+
+	now := time.Now().UTC()
+	periods := usagePeriods(now)
+	days, months := sortedUsage(usage)
+
+	report["day"] = usagePeriodView{
+		Period:   now.Format("2006-01-02"),
+		Requests: usage[periods.Day],
+		Limit:    limits.Daily,
+		ResetAt:  nextDay(now).Format(time.RFC3339),
+	}
+	report["month"] = usagePeriodView{
+		Period:   now.Format("2006-01"),
+		Requests: usage[periods.Month],
+		Limit:    limits.Monthly,
+		ResetAt:  nextMonth(now).Format(time.RFC3339),
+	}
+	report["history"] = map[string]interface{}{
+		"days":   days,
+		"months": months,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    report,
+	})
+}
+
+func nextDay(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+func nextMonth(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
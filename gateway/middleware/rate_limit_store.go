@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Через сколько счётчик полностью восстановится
+	ResetAfter time.Duration
+	// Через сколько можно повторить отклонённый запрос
+	RetryAfter time.Duration
+}
+
+// RateLimitStore хранит состояние лимитов. Реализации считают по алгоритму GCRA:
+// для каждого ключа хранится одно число — теоретическое время прихода следующего запроса (TAT),
+// а запись истекает сама, когда счётчик полностью восстановился.
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string, limit Limit) (RateLimitResult, error)
+}
+
+// gcra вычисляет решение по текущему TAT и возвращает новый TAT
+func gcra(now, tat time.Time, limit Limit) (time.Time, RateLimitResult) {
+	interval := time.Second / time.Duration(limit.RPS)
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-time.Duration(limit.Burst) * interval)
+	if now.Before(allowAt) {
+		return tat, RateLimitResult{
+			Allowed:    false,
+			ResetAfter: tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}
+	}
+
+	return newTat, RateLimitResult{
+		Allowed:    true,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTat.Sub(now),
+	}
+}
+
+// MemoryRateLimitStore хранит счётчики в памяти процесса.
+// Подходит для одного экземпляра шлюза: у каждой реплики будут свои квоты.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	nextSweep time.Time
+}
+
+// Как часто удалять истёкшие записи
+const memorySweepInterval = time.Minute
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		tats:      make(map[string]time.Time),
+		nextSweep: time.Now().Add(memorySweepInterval),
+	}
+}
+
+func (s *MemoryRateLimitStore) Allow(ctx context.Context, key string, limit Limit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.After(s.nextSweep) {
+		s.sweep(now)
+	}
+
+	tat, result := gcra(now, s.tats[key], limit)
+	s.tats[key] = tat
+	return result, nil
+}
+
+// sweep удаляет записи с истёкшим TTL: их TAT уже в прошлом, счётчик восстановлен
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, tat := range s.tats {
+		if !tat.After(now) {
+			delete(s.tats, key)
+		}
+	}
+	s.nextSweep = now.Add(memorySweepInterval)
+}
@@ -1,79 +1,177 @@
 package middleware
 
 import (
+	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
-
-	"golang.org/x/time/rate"
 )
 
-type visitor struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
+// Группа для маршрутов без собственного лимита
+const DefaultRateLimitGroup = "default"
+
+// Префикс ключей лимита по IP, который действует до проверки JWT
+const ipRateLimitPrefix = "preauth|ip:"
+
+type Limit struct {
+	RPS   int
+	Burst int
 }
 
 type RateLimiter struct {
-	visitors map[string]*visitor
-	mu       sync.RWMutex
-	rps      int
-	burst    int
+	store RateLimitStore
+	mu    sync.RWMutex
+	rps   int
+	burst int
+	// Множители лимита по ролям, например admin: 10
+	tiers          map[string]float64
+	trustedProxies []*net.IPNet
+	// Лимит по IP до аутентификации, нулевой — выключен
+	ipLimit Limit
 }
 
-func NewRateLimiter(rps, burst int) *RateLimiter {
-	rl := &RateLimiter{
-		visitors: make(map[string]*visitor),
-		rps:      rps,
-		burst:    burst,
+func NewRateLimiter(store RateLimitStore, rps, burst int) *RateLimiter {
+	return &RateLimiter{
+		store: store,
+		rps:   rps,
+		burst: burst,
+		tiers: make(map[string]float64),
 	}
+}
 
-	// Очистка старых посетителей каждые 5 минут
-	go rl.cleanupVisitors()
+// SetLimit меняет лимит по умолчанию на лету
+func (rl *RateLimiter) SetLimit(rps, burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 
-	return rl
+	rl.rps = rps
+	rl.burst = burst
 }
 
-func (rl *RateLimiter) getVisitor(ip string) *rate.Limiter {
+// SetIPLimit задаёт лимит по IP, который IPLimit применяет до проверки JWT
+func (rl *RateLimiter) SetIPLimit(rps, burst int) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	v, exists := rl.visitors[ip]
-	if !exists {
-		limiter := rate.NewLimiter(rate.Limit(rl.rps), rl.burst)
-		rl.visitors[ip] = &visitor{limiter: limiter, lastSeen: time.Now()}
-		return limiter
-	}
+	rl.ipLimit = Limit{RPS: rps, Burst: burst}
+}
+
+func (rl *RateLimiter) SetTiers(tiers map[string]float64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 
-	v.lastSeen = time.Now()
-	return v.limiter
+	rl.tiers = tiers
 }
 
-func (rl *RateLimiter) cleanupVisitors() {
-	for {
-		time.Sleep(5 * time.Minute)
+func (rl *RateLimiter) SetTrustedProxies(trustedProxies []*net.IPNet) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 
-		rl.mu.Lock()
-		for ip, v := range rl.visitors {
-			if time.Since(v.lastSeen) > 10*time.Minute {
-				delete(rl.visitors, ip)
-			}
-		}
-		rl.mu.Unlock()
-	}
+	rl.trustedProxies = trustedProxies
 }
 
+// Middleware применяет лимит по умолчанию
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return rl.Limit(DefaultRateLimitGroup, nil)(next)
+}
+
+// IPLimit ограничивает запросы по IP клиента до аутентификации: поток запросов
+// с поддельными или просроченными токенами отсекается, не доходя до проверки подписи
+// и отзыва. Лимит общий для всех маршрутов, поэтому он грубее лимита по пользователю.
+func (rl *RateLimiter) IPLimit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
-		limiter := rl.getVisitor(ip)
+		rl.mu.RLock()
+		limit := rl.ipLimit
+		key := ipRateLimitPrefix + ClientIP(r, rl.trustedProxies)
+		rl.mu.RUnlock()
 
-		if !limiter.Allow() {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusTooManyRequests)
-			w.Write([]byte(`{"success":false,"error":{"code":"RATE_LIMIT_EXCEEDED","message":"too many requests"}}`))
+		if limit.RPS <= 0 {
+			next.ServeHTTP(w, r)
 			return
 		}
+		rl.serve(w, r, next, key, limit)
+	})
+}
+
+// Limit ограничивает запросы внутри группы маршрутов. Если limit не задан, действует лимит по умолчанию.
+// Аутентифицированные пользователи учитываются по UserID, остальные — по IP клиента,
+// поэтому middleware должен стоять после JWTAuthMiddleware.
+func (rl *RateLimiter) Limit(group string, limit *Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, effective := rl.resolve(r, group, limit)
+			rl.serve(w, r, next, key, effective)
+		})
+	}
+}
 
+// serve пропускает запрос дальше или отвечает 429, если лимит ключа исчерпан
+func (rl *RateLimiter) serve(w http.ResponseWriter, r *http.Request, next http.Handler, key string, effective Limit) {
+	result, err := rl.store.Allow(r.Context(), key, effective)
+	if err != nil {
+		// Недоступное хранилище не должно останавливать весь трафик
+		log.Printf("Rate limit store error, request allowed: %v", err)
 		next.ServeHTTP(w, r)
-	})
+		return
+	}
+
+	// Заголовки по draft-ietf-httpapi-ratelimit-headers; следующий лимит в цепочке их перезапишет
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(effective.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+	if !result.Allowed {
+		retryAfter := ceilSeconds(result.RetryAfter)
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"success":false,"error":{"code":"RATE_LIMIT_EXCEEDED","message":"too many requests"}}`))
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+// resolve возвращает ключ посетителя и действующий для него лимит
+func (rl *RateLimiter) resolve(r *http.Request, group string, limit *Limit) (string, Limit) {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	effective := Limit{RPS: rl.rps, Burst: rl.burst}
+	if limit != nil {
+		effective = *limit
+	}
+
+	claims, ok := r.Context().Value(UserContextKey).(*Claims)
+	if !ok {
+		return group + "|ip:" + ClientIP(r, rl.trustedProxies), effective
+	}
+
+	multiplier := tierMultiplier(claims.Roles, rl.tiers)
+	effective.RPS = int(math.Max(1, math.Round(float64(effective.RPS)*multiplier)))
+	effective.Burst = int(math.Max(1, math.Round(float64(effective.Burst)*multiplier)))
+
+	return group + "|user:" + claims.UserID.String(), effective
+}
+
+// tierMultiplier возвращает множитель для ролей пользователя.
+// При нескольких ролях действует самый щедрый уровень.
+func tierMultiplier(roles []string, tiers map[string]float64) float64 {
+	multiplier, found := 1.0, false
+	for _, role := range roles {
+		if tier, exists := tiers[role]; exists && (!found || tier > multiplier) {
+			multiplier, found = tier, true
+		}
+	}
+	return multiplier
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
 }
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// GCRA на стороне Redis: чтение и обновление TAT выполняются атомарно,
+// время берётся с сервера Redis, чтобы реплики шлюза не зависели от расхождения часов.
+// Все величины — в микросекундах.
+var gcraScript = redis.NewScript(`
+if redis.replicate_commands then
+  redis.replicate_commands()
+end
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat or tat < now then
+  tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - burst * interval
+if now < allow_at then
+  return {0, 0, tat - now, allow_at - now}
+end
+
+-- Большие числа форматируем явно, иначе Lua запишет их в экспоненциальной форме с потерей точности
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / interval), new_tat - now, 0}
+`)
+
+// RedisRateLimitStore хранит счётчики в Redis (или совместимом сервере),
+// так что все реплики шлюза делят общие квоты
+type RedisRateLimitStore struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+func NewRedisRateLimitStore(client redis.UniversalClient, keyPrefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (s *RedisRateLimitStore) Allow(ctx context.Context, key string, limit Limit) (RateLimitResult, error) {
+	interval := (time.Second / time.Duration(limit.RPS)).Microseconds()
+
+	values, err := gcraScript.Run(ctx, s.client, []string{s.keyPrefix + key}, interval, limit.Burst).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(values) != 4 {
+		return RateLimitResult{}, fmt.Errorf("unexpected rate limit script result: %v", values)
+	}
+
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		ResetAfter: time.Duration(values[2]) * time.Microsecond,
+		RetryAfter: time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
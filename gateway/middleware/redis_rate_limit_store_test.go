@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const testKeyPrefix = "test:ratelimit:"
+
+// testRedis — miniredis с управляемым временем: TIME внутри скрипта и TTL ключей
+// сдвигаются вместе
+type testRedis struct {
+	server *miniredis.Miniredis
+	now    time.Time
+}
+
+func newTestRedis(t *testing.T) *testRedis {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.SetTime(now)
+	return &testRedis{server: server, now: now}
+}
+
+func (r *testRedis) advance(d time.Duration) {
+	r.now = r.now.Add(d)
+	r.server.SetTime(r.now)
+	r.server.FastForward(d)
+}
+
+// newStore — отдельный клиент, как у отдельной реплики шлюза
+func (r *testRedis) newStore(t *testing.T) *RedisRateLimitStore {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: r.server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisRateLimitStore(client, testKeyPrefix)
+}
+
+func allow(t *testing.T, store RateLimitStore, key string, limit Limit) RateLimitResult {
+	t.Helper()
+
+	result, err := store.Allow(context.Background(), key, limit)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	return result
+}
+
+func TestRedisRateLimitStoreBurst(t *testing.T) {
+	r := newTestRedis(t)
+	store := r.newStore(t)
+	limit := Limit{RPS: 10, Burst: 5}
+
+	for i := 0; i < limit.Burst; i++ {
+		result := allow(t, store, "user:1", limit)
+		if !result.Allowed {
+			t.Fatalf("request %d denied within burst", i+1)
+		}
+		if want := limit.Burst - 1 - i; result.Remaining != want {
+			t.Errorf("request %d: remaining = %d, want %d", i+1, result.Remaining, want)
+		}
+		if want := time.Duration(i+1) * 100 * time.Millisecond; result.ResetAfter != want {
+			t.Errorf("request %d: reset after = %s, want %s", i+1, result.ResetAfter, want)
+		}
+	}
+
+	result := allow(t, store, "user:1", limit)
+	if result.Allowed {
+		t.Fatal("request over burst allowed")
+	}
+	if result.RetryAfter != 100*time.Millisecond {
+		t.Errorf("retry after = %s, want 100ms", result.RetryAfter)
+	}
+	if result.ResetAfter != 500*time.Millisecond {
+		t.Errorf("reset after = %s, want 500ms", result.ResetAfter)
+	}
+
+	// Отказ не сдвигает TAT: повтор сразу получает тот же ответ
+	if again := allow(t, store, "user:1", limit); again != result {
+		t.Errorf("denied request changed state: %+v, then %+v", result, again)
+	}
+
+	// Другой ключ считается отдельно
+	if !allow(t, store, "user:2", limit).Allowed {
+		t.Error("request of another key denied")
+	}
+}
+
+func TestRedisRateLimitStoreRefill(t *testing.T) {
+	r := newTestRedis(t)
+	store := r.newStore(t)
+	limit := Limit{RPS: 10, Burst: 5}
+
+	for i := 0; i < limit.Burst; i++ {
+		allow(t, store, "user:1", limit)
+	}
+
+	// За 100 мс восстанавливается ровно один запрос
+	r.advance(99 * time.Millisecond)
+	if result := allow(t, store, "user:1", limit); result.Allowed {
+		t.Fatal("request allowed before the interval passed")
+	} else if result.RetryAfter != time.Millisecond {
+		t.Errorf("retry after = %s, want 1ms", result.RetryAfter)
+	}
+	r.advance(time.Millisecond)
+	if !allow(t, store, "user:1", limit).Allowed {
+		t.Fatal("request denied after the interval passed")
+	}
+	if allow(t, store, "user:1", limit).Allowed {
+		t.Fatal("second request allowed after a single interval")
+	}
+
+	// Через burst интервалов доступен весь burst, но не больше
+	r.advance(time.Duration(limit.Burst) * 100 * time.Millisecond)
+	for i := 0; i < limit.Burst; i++ {
+		if !allow(t, store, "user:1", limit).Allowed {
+			t.Fatalf("request %d denied after full refill", i+1)
+		}
+	}
+	if allow(t, store, "user:1", limit).Allowed {
+		t.Fatal("refill exceeded burst")
+	}
+}
+
+func TestRedisRateLimitStoreTTL(t *testing.T) {
+	r := newTestRedis(t)
+	store := r.newStore(t)
+	limit := Limit{RPS: 10, Burst: 5}
+	key := testKeyPrefix + "user:1"
+
+	allow(t, store, "user:1", limit)
+	if ttl := r.server.TTL(key); ttl != 100*time.Millisecond {
+		t.Errorf("ttl after one request = %s, want 100ms", ttl)
+	}
+
+	for i := 1; i < limit.Burst; i++ {
+		allow(t, store, "user:1", limit)
+	}
+	if ttl := r.server.TTL(key); ttl != 500*time.Millisecond {
+		t.Errorf("ttl after burst = %s, want 500ms", ttl)
+	}
+
+	// Запись живёт ровно до полного восстановления счётчика
+	r.advance(499 * time.Millisecond)
+	if !r.server.Exists(key) {
+		t.Fatal("key expired before the limit was restored")
+	}
+	r.advance(time.Millisecond)
+	if r.server.Exists(key) {
+		t.Fatal("key outlived the limit restoration")
+	}
+
+	result := allow(t, store, "user:1", limit)
+	if !result.Allowed || result.Remaining != limit.Burst-1 {
+		t.Errorf("request after expiry = %+v, want allowed with remaining %d", result, limit.Burst-1)
+	}
+}
+
+func TestRedisRateLimitStoreSharedAcrossInstances(t *testing.T) {
+	r := newTestRedis(t)
+	first := r.newStore(t)
+	second := r.newStore(t)
+	limit := Limit{RPS: 10, Burst: 4}
+
+	// Реплики по очереди расходуют общий burst
+	for i := 0; i < limit.Burst; i++ {
+		store := first
+		if i%2 == 1 {
+			store = second
+		}
+		result := allow(t, store, "user:1", limit)
+		if !result.Allowed {
+			t.Fatalf("request %d denied within shared burst", i+1)
+		}
+		if want := limit.Burst - 1 - i; result.Remaining != want {
+			t.Errorf("request %d: remaining = %d, want %d", i+1, result.Remaining, want)
+		}
+	}
+	if allow(t, first, "user:1", limit).Allowed || allow(t, second, "user:1", limit).Allowed {
+		t.Fatal("instances did not share the limit")
+	}
+
+	// Восстановление тоже общее
+	r.advance(100 * time.Millisecond)
+	if !allow(t, second, "user:1", limit).Allowed {
+		t.Fatal("refilled request denied")
+	}
+	if allow(t, first, "user:1", limit).Allowed {
+		t.Fatal("refill counted twice across instances")
+	}
+}
+
+// Скрипт в Redis и gcra в памяти должны принимать одинаковые решения
+func TestRedisRateLimitStoreMatchesMemoryGCRA(t *testing.T) {
+	r := newTestRedis(t)
+	store := r.newStore(t)
+	limit := Limit{RPS: 3, Burst: 2}
+
+	tat := r.now
+	steps := []time.Duration{0, 0, 0, 100 * time.Millisecond, 250 * time.Millisecond, 0, time.Second, 0, 0, 5 * time.Second}
+	for i, step := range steps {
+		r.advance(step)
+
+		var want RateLimitResult
+		tat, want = gcra(r.now, tat, limit)
+		got := allow(t, store, "user:1", limit)
+
+		// Redis считает в микросекундах
+		want.ResetAfter = want.ResetAfter.Truncate(time.Microsecond)
+		want.RetryAfter = want.RetryAfter.Truncate(time.Microsecond)
+		if got != want {
+			t.Errorf("step %d: redis = %+v, memory = %+v", i+1, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Проверка лимитов и увеличение счётчиков выполняются атомарно.
+// Все периоды пользователя или ключа API лежат в одном хеше; в начале нового дня из него удаляются устаревшие поля.
+var consumeUsageScript = redis.NewScript(`
+local day = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+local month = tonumber(redis.call('HGET', KEYS[1], ARGV[2]) or '0')
+local daily = tonumber(ARGV[3])
+local monthly = tonumber(ARGV[4])
+
+if (daily > 0 and day >= daily) or (monthly > 0 and month >= monthly) then
+  return {0, day, month}
+end
+
+day = redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+month = redis.call('HINCRBY', KEYS[1], ARGV[2], 1)
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+
+if day == 1 then
+  for _, period in ipairs(redis.call('HKEYS', KEYS[1])) do
+    if (string.sub(period, 1, 4) == 'day:' and period < ARGV[6]) or
+       (string.sub(period, 1, 6) == 'month:' and period < ARGV[7]) then
+      redis.call('HDEL', KEYS[1], period)
+    end
+  end
+end
+
+return {1, day, month}
+`)
+
+// Ключ живёт, пока по нему идут запросы, и ещё столько, сколько хранится история
+const redisUsageTTL = 400 * 24 * time.Hour
+
+// RedisUsageStore хранит счётчики в Redis, общие для всех реплик шлюза
+type RedisUsageStore struct {
+	client    redis.UniversalClient
+	keyPrefix string
+}
+
+func NewRedisUsageStore(client redis.UniversalClient, keyPrefix string) *RedisUsageStore {
+	return &RedisUsageStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (s *RedisUsageStore) Consume(ctx context.Context, subject string, periods UsagePeriods, limits QuotaLimits) (Usage, bool, error) {
+	cutoff := usageCutoff(time.Now())
+	values, err := consumeUsageScript.Run(ctx, s.client, []string{s.keyPrefix + subject},
+		periods.Day, periods.Month, limits.Daily, limits.Monthly,
+		int64(redisUsageTTL.Seconds()), cutoff.Day, cutoff.Month,
+	).Int64Slice()
+	if err != nil {
+		return Usage{}, false, err
+	}
+	if len(values) != 3 {
+		return Usage{}, false, fmt.Errorf("unexpected usage script result: %v", values)
+	}
+
+	return Usage{Day: values[1], Month: values[2]}, values[0] == 1, nil
+}
+
+func (s *RedisUsageStore) Usage(ctx context.Context, subject string) (map[string]int64, error) {
+	fields, err := s.client.HGetAll(ctx, s.keyPrefix+subject).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]int64, len(fields))
+	for period, value := range fields {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid usage counter %s: %w", period, err)
+		}
+		usage[period] = count
+	}
+	return usage, nil
+}
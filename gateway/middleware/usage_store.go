@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Счётчики хранятся по периодам: "day:2006-01-02" и "month:2006-01" (UTC)
+const (
+	dayPeriodPrefix   = "day:"
+	monthPeriodPrefix = "month:"
+)
+
+// Сколько хранить историю: дни — за прошлый месяц, месяцы — за год
+const (
+	usageDayRetention   = 35 * 24 * time.Hour
+	usageMonthRetention = 13
+)
+
+type UsagePeriods struct {
+	Day   string
+	Month string
+}
+
+func usagePeriods(now time.Time) UsagePeriods {
+	now = now.UTC()
+	return UsagePeriods{
+		Day:   dayPeriodPrefix + now.Format("2006-01-02"),
+		Month: monthPeriodPrefix + now.Format("2006-01"),
+	}
+}
+
+// usageCutoff возвращает периоды, всё что раньше которых можно удалить
+func usageCutoff(now time.Time) UsagePeriods {
+	now = now.UTC()
+	return UsagePeriods{
+		Day:   dayPeriodPrefix + now.Add(-usageDayRetention).Format("2006-01-02"),
+		Month: monthPeriodPrefix + now.AddDate(0, -usageMonthRetention, 0).Format("2006-01"),
+	}
+}
+
+// isExpiredPeriod сравнивает периоды как строки: формат дат это позволяет
+func isExpiredPeriod(period string, cutoff UsagePeriods) bool {
+	if strings.HasPrefix(period, dayPeriodPrefix) {
+		return period < cutoff.Day
+	}
+	if strings.HasPrefix(period, monthPeriodPrefix) {
+		return period < cutoff.Month
+	}
+	return false
+}
+
+// QuotaLimits — лимиты запросов на период, 0 — без ограничения
+type QuotaLimits struct {
+	Daily   int64
+	Monthly int64
+}
+
+type Usage struct {
+	Day   int64
+	Month int64
+}
+
+func (l QuotaLimits) exceeded(usage Usage) bool {
+	return (l.Daily > 0 && usage.Day >= l.Daily) || (l.Monthly > 0 && usage.Month >= l.Monthly)
+}
+
+// UsageStore ведёт счётчики запросов. Subject — ID пользователя или apikey:<id> для ключа API.
+type UsageStore interface {
+	// Consume учитывает запрос, если он укладывается в лимиты, и возвращает использование после него
+	Consume(ctx context.Context, subject string, periods UsagePeriods, limits QuotaLimits) (Usage, bool, error)
+	// Usage возвращает все сохранённые счётчики subject по периодам
+	Usage(ctx context.Context, subject string) (map[string]int64, error)
+}
+
+// MemoryUsageStore хранит счётчики в памяти и периодически сохраняет снимок в файл,
+// чтобы использование не обнулялось при перезапуске шлюза
+type MemoryUsageStore struct {
+	mu       sync.Mutex
+	counters map[string]map[string]int64
+	file     string
+	dirty    bool
+}
+
+// NewMemoryUsageStore загружает снимок из файла, если он есть. Пустой путь — без сохранения.
+func NewMemoryUsageStore(file string) (*MemoryUsageStore, error) {
+	s := &MemoryUsageStore{
+		counters: make(map[string]map[string]int64),
+		file:     file,
+	}
+	if file == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.counters); err != nil {
+		return nil, fmt.Errorf("invalid usage snapshot %s: %w", file, err)
+	}
+	return s, nil
+}
+
+func (s *MemoryUsageStore) Consume(ctx context.Context, subject string, periods UsagePeriods, limits QuotaLimits) (Usage, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counters, exists := s.counters[subject]
+	if !exists {
+		counters = make(map[string]int64)
+		s.counters[subject] = counters
+	}
+
+	usage := Usage{Day: counters[periods.Day], Month: counters[periods.Month]}
+	if limits.exceeded(usage) {
+		return usage, false, nil
+	}
+
+	counters[periods.Day]++
+	counters[periods.Month]++
+	s.dirty = true
+	return Usage{Day: counters[periods.Day], Month: counters[periods.Month]}, true, nil
+}
+
+func (s *MemoryUsageStore) Usage(ctx context.Context, subject string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usage := make(map[string]int64, len(s.counters[subject]))
+	for period, count := range s.counters[subject] {
+		usage[period] = count
+	}
+	return usage, nil
+}
+
+// Snapshot удаляет устаревшие периоды и записывает счётчики в файл.
+// Запись идёт через временный файл, чтобы сбой посередине не испортил предыдущий снимок.
+func (s *MemoryUsageStore) Snapshot() error {
+	s.mu.Lock()
+	cutoff := usageCutoff(time.Now())
+	for subject, counters := range s.counters {
+		for period := range counters {
+			if isExpiredPeriod(period, cutoff) {
+				delete(counters, period)
+				s.dirty = true
+			}
+		}
+		if len(counters) == 0 {
+			delete(s.counters, subject)
+		}
+	}
+	if s.file == "" || !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.counters)
+	s.dirty = false
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.file)
+}
+
+// Run сохраняет снимки с заданным интервалом и последний раз — при остановке
+func (s *MemoryUsageStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Snapshot(); err != nil {
+				log.Printf("Failed to save usage snapshot: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := s.Snapshot(); err != nil {
+				log.Printf("Failed to save usage snapshot: %v", err)
+			}
+		}
+	}
+}
+
+type UsagePeriod struct {
+	Period   string `json:"period"`
+	Requests int64  `json:"requests"`
+}
+
+// sortedUsage раскладывает счётчики по дням и месяцам в хронологическом порядке
+func sortedUsage(usage map[string]int64) (days, months []UsagePeriod) {
+	days, months = []UsagePeriod{}, []UsagePeriod{}
+	for period, requests := range usage {
+		if day, ok := strings.CutPrefix(period, dayPeriodPrefix); ok {
+			days = append(days, UsagePeriod{Period: day, Requests: requests})
+		} else if month, ok := strings.CutPrefix(period, monthPeriodPrefix); ok {
+			months = append(months, UsagePeriod{Period: month, Requests: requests})
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Period < days[j].Period })
+	sort.Slice(months, func(i, j int) bool { return months[i].Period < months[j].Period })
+	return days, months
+}
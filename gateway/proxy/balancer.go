@@ -0,0 +1,188 @@
+package proxy
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	StrategyRoundRobin       = "round_robin"
+	StrategyLeastConnections = "least_connections"
+	StrategyWeighted         = "weighted"
+)
+
+type InstanceConfig struct {
+	URL    string
+	Weight int
+}
+
+// ParseInstances разбирает список адресов через запятую.
+// Вес экземпляра задаётся суффиксом ";weight=N", например
+// "http://order-1:3002;weight=3,http://order-2:3002".
+func ParseInstances(raw string) ([]InstanceConfig, error) {
+	var instances []InstanceConfig
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		instance := InstanceConfig{Weight: 1}
+		fields := strings.Split(part, ";")
+		instance.URL = strings.TrimSpace(fields[0])
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok || key != "weight" {
+				return nil, fmt.Errorf("invalid instance option %q in %q", field, part)
+			}
+			weight, err := strconv.Atoi(value)
+			if err != nil || weight < 1 {
+				return nil, fmt.Errorf("invalid weight %q in %q", value, part)
+			}
+			instance.Weight = weight
+		}
+		instances = append(instances, instance)
+	}
+
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("no instances in %q", raw)
+	}
+	return instances, nil
+}
+
+type Instance struct {
+	URL    *url.URL
+	Weight int
+
+	healthy     atomic.Bool
+	activeConns atomic.Int64
+	// Предохранитель экземпляра: отказы одного экземпляра не отключают весь upstream
+	breaker *CircuitBreaker
+
+	// Счётчики активных проверок, меняются только health checker'ом
+	mu                   sync.Mutex
+	consecutiveFailures  int
+	consecutiveSuccesses int
+	lastError            string
+}
+
+func newInstance(cfg InstanceConfig, breakerCfg BreakerConfig) (*Instance, error) {
+	target, err := url.Parse(cfg.URL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("invalid instance URL %q", cfg.URL)
+	}
+	weight := cfg.Weight
+	if weight < 1 {
+		weight = 1
+	}
+
+	instance := &Instance{URL: target, Weight: weight, breaker: NewCircuitBreaker(breakerCfg)}
+	// До первой проверки считаем экземпляр доступным
+	instance.healthy.Store(true)
+	return instance, nil
+}
+
+func (i *Instance) Healthy() bool {
+	return i.healthy.Load()
+}
+
+func (i *Instance) ActiveConnections() int64 {
+	return i.activeConns.Load()
+}
+
+type InstanceStatus struct {
+	URL               string          `json:"url"`
+	Weight            int             `json:"weight"`
+	Healthy           bool            `json:"healthy"`
+	ActiveConnections int64           `json:"activeConnections"`
+	LastError         string          `json:"lastError,omitempty"`
+	Breaker           BreakerSnapshot `json:"breaker"`
+}
+
+func (i *Instance) status() InstanceStatus {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return InstanceStatus{
+		URL:               i.URL.String(),
+		Weight:            i.Weight,
+		Healthy:           i.Healthy(),
+		ActiveConnections: i.ActiveConnections(),
+		LastError:         i.lastError,
+		Breaker:           i.breaker.Snapshot(),
+	}
+}
+
+type Balancer interface {
+	// Next выбирает экземпляр из списка кандидатов; список не пуст
+	Next(candidates []*Instance) *Instance
+}
+
+func NewBalancer(strategy string) (Balancer, error) {
+	switch strategy {
+	case "", StrategyRoundRobin:
+		return &roundRobinBalancer{}, nil
+	case StrategyLeastConnections:
+		return &leastConnectionsBalancer{}, nil
+	case StrategyWeighted:
+		return &weightedBalancer{current: make(map[*Instance]int)}, nil
+	default:
+		return nil, fmt.Errorf("unknown load balancing strategy %q", strategy)
+	}
+}
+
+type roundRobinBalancer struct {
+	counter atomic.Uint64
+}
+
+func (b *roundRobinBalancer) Next(candidates []*Instance) *Instance {
+	n := b.counter.Add(1) - 1
+	return candidates[n%uint64(len(candidates))]
+}
+
+type leastConnectionsBalancer struct {
+	counter atomic.Uint64
+}
+
+func (b *leastConnectionsBalancer) Next(candidates []*Instance) *Instance {
+	// Начинаем с разных позиций, чтобы при равной нагрузке не выбирать всегда первый
+	offset := int(b.counter.Add(1) % uint64(len(candidates)))
+
+	var best *Instance
+	for i := range candidates {
+		candidate := candidates[(offset+i)%len(candidates)]
+		if best == nil || candidate.ActiveConnections() < best.ActiveConnections() {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// weightedBalancer реализует плавный взвешенный round-robin (как в nginx).
+// Текущие веса хранит сам балансировщик: экземпляры переиспользуются новым пулом при перезагрузке
+// конфигурации, и старый балансировщик, из которого ещё выбирают запросы, не должен их делить с новым.
+type weightedBalancer struct {
+	mu      sync.Mutex
+	current map[*Instance]int
+}
+
+func (b *weightedBalancer) Next(candidates []*Instance) *Instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	total := 0
+	var best *Instance
+	for _, candidate := range candidates {
+		b.current[candidate] += candidate.Weight
+		total += candidate.Weight
+		if best == nil || b.current[candidate] > b.current[best] {
+			best = candidate
+		}
+	}
+	b.current[best] -= total
+	return best
+}
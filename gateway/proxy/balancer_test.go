@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func newTestInstances(t *testing.T, weights map[string]int, order ...string) []*Instance {
+	t.Helper()
+
+	instances := make([]*Instance, 0, len(order))
+	for _, host := range order {
+		instances = append(instances, &Instance{URL: &url.URL{Scheme: "http", Host: host}, Weight: weights[host]})
+	}
+	return instances
+}
+
+// Веса 5:1:1 дают ту же последовательность, что и в nginx: a a b a c a a
+func TestWeightedBalancerSmoothSequence(t *testing.T) {
+	instances := newTestInstances(t, map[string]int{"a": 5, "b": 1, "c": 1}, "a", "b", "c")
+	balancer, err := NewBalancer(StrategyWeighted)
+	if err != nil {
+		t.Fatalf("NewBalancer: %v", err)
+	}
+
+	want := "aabacaa"
+	for round := 0; round < 3; round++ {
+		got := ""
+		for range want {
+			got += balancer.Next(instances).URL.Host
+		}
+		if got != want {
+			t.Errorf("round %d: sequence = %s, want %s", round+1, got, want)
+		}
+	}
+}
+
+// После перезагрузки конфигурации старый и новый пулы выбирают из одних и тех же экземпляров.
+// Каждый балансировщик ведёт свои веса: запускать с -race.
+func TestWeightedBalancersShareInstances(t *testing.T) {
+	instances := newTestInstances(t, map[string]int{"a": 3, "b": 1}, "a", "b")
+	balancers := make([]Balancer, 2)
+	for i := range balancers {
+		balancer, err := NewBalancer(StrategyWeighted)
+		if err != nil {
+			t.Fatalf("NewBalancer: %v", err)
+		}
+		balancers[i] = balancer
+	}
+
+	const picks = 400
+	counts := make([]map[string]int, len(balancers))
+	var wg sync.WaitGroup
+	for i, balancer := range balancers {
+		counts[i] = make(map[string]int)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < picks; j++ {
+				counts[i][balancer.Next(instances).URL.Host]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Общие экземпляры не сбивают распределение ни одного из балансировщиков
+	for i, count := range counts {
+		if count["a"] != picks*3/4 || count["b"] != picks/4 {
+			t.Errorf("balancer %d: picks = %v, want a=%d b=%d", i+1, count, picks*3/4, picks/4)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+type BreakerConfig struct {
+	// Доля неуспешных запросов в окне, при которой цепь размыкается
+	FailureRatio float64
+	// Минимальное число запросов в окне, прежде чем оценивать долю ошибок
+	MinRequests int
+	// Окно подсчёта в закрытом состоянии
+	Window time.Duration
+	// Сколько цепь остаётся разомкнутой перед пробными запросами
+	OpenTimeout time.Duration
+	// Сколько пробных запросов пропускается в полуоткрытом состоянии
+	HalfOpenRequests int
+}
+
+type breakerResult int
+
+const (
+	resultSuccess breakerResult = iota
+	resultFailure
+	// Запрос прерван клиентом — не влияет на состояние цепи
+	resultIgnored
+)
+
+type BreakerSnapshot struct {
+	State     string     `json:"state"`
+	Requests  int        `json:"requests"`
+	Failures  int        `json:"failures"`
+	OpenedAt  *time.Time `json:"openedAt,omitempty"`
+	RetryAt   *time.Time `json:"retryAt,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+type CircuitBreaker struct {
+	cfg BreakerConfig
+
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	inFlight    int
+	lastError   string
+}
+
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	if cfg.HalfOpenRequests < 1 {
+		cfg.HalfOpenRequests = 1
+	}
+	return &CircuitBreaker{
+		cfg:         cfg,
+		state:       StateClosed,
+		windowStart: time.Now(),
+	}
+}
+
+// Allow проверяет, можно ли отправить запрос в upstream.
+// Возвращённую функцию нужно вызвать ровно один раз с результатом запроса.
+func (cb *CircuitBreaker) Allow() (func(breakerResult), error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := time.Now()
+	cb.advance(now)
+
+	switch cb.state {
+	case StateOpen:
+		return nil, ErrCircuitOpen
+	case StateHalfOpen:
+		if cb.inFlight >= cb.cfg.HalfOpenRequests {
+			return nil, ErrCircuitOpen
+		}
+	}
+
+	cb.inFlight++
+	generation := cb.generation
+	var once sync.Once
+	return func(result breakerResult) {
+		once.Do(func() { cb.record(generation, result) })
+	}, nil
+}
+
+// Ready сообщает, пропустит ли Allow запрос сейчас. Балансировщик выбирает только из таких экземпляров.
+func (cb *CircuitBreaker) Ready() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.advance(time.Now())
+	switch cb.state {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		return cb.inFlight < cb.cfg.HalfOpenRequests
+	}
+	return true
+}
+
+// RecordError сохраняет текст последней ошибки для /health
+func (cb *CircuitBreaker) RecordError(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.lastError = err.Error()
+}
+
+func (cb *CircuitBreaker) Snapshot() BreakerSnapshot {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.advance(time.Now())
+
+	snapshot := BreakerSnapshot{
+		State:     cb.state.String(),
+		Requests:  cb.requests,
+		Failures:  cb.failures,
+		LastError: cb.lastError,
+	}
+	if cb.state != StateClosed {
+		openedAt := cb.openedAt
+		snapshot.OpenedAt = &openedAt
+	}
+	if cb.state == StateOpen {
+		retryAt := cb.openedAt.Add(cb.cfg.OpenTimeout)
+		snapshot.RetryAt = &retryAt
+	}
+	return snapshot
+}
+
+// RetryAfter возвращает время до следующей пробной попытки
+func (cb *CircuitBreaker) RetryAfter() time.Duration {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != StateOpen {
+		return 0
+	}
+	return time.Until(cb.openedAt.Add(cb.cfg.OpenTimeout))
+}
+
+func (cb *CircuitBreaker) record(generation uint64, result breakerResult) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	// Результат относится к предыдущему состоянию цепи
+	if generation != cb.generation {
+		return
+	}
+	cb.inFlight--
+
+	now := time.Now()
+	switch cb.state {
+	case StateClosed:
+		if result == resultIgnored {
+			return
+		}
+		cb.requests++
+		if result == resultFailure {
+			cb.failures++
+		}
+		if cb.requests >= cb.cfg.MinRequests &&
+			float64(cb.failures)/float64(cb.requests) >= cb.cfg.FailureRatio {
+			cb.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		switch result {
+		case resultSuccess:
+			cb.setState(StateClosed, now)
+		case resultFailure:
+			cb.setState(StateOpen, now)
+		}
+	}
+}
+
+// advance переводит цепь по таймерам: open → half-open, сброс окна в closed
+func (cb *CircuitBreaker) advance(now time.Time) {
+	switch cb.state {
+	case StateOpen:
+		if now.Sub(cb.openedAt) >= cb.cfg.OpenTimeout {
+			cb.setState(StateHalfOpen, now)
+		}
+	case StateClosed:
+		if cb.cfg.Window > 0 && now.Sub(cb.windowStart) >= cb.cfg.Window {
+			cb.windowStart = now
+			cb.requests = 0
+			cb.failures = 0
+		}
+	}
+}
+
+func (cb *CircuitBreaker) setState(state BreakerState, now time.Time) {
+	if state == StateOpen {
+		cb.openedAt = now
+	}
+	if state == StateClosed {
+		cb.lastError = ""
+	}
+	cb.state = state
+	cb.generation++
+	cb.inFlight = 0
+	cb.windowStart = now
+	cb.requests = 0
+	cb.failures = 0
+}
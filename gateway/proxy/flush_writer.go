@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// maxLatencyWriter сбрасывает записанные данные клиенту не позже, чем через latency.
+// При отрицательном latency сброс выполняется после каждой записи.
+type maxLatencyWriter struct {
+	dst     io.Writer
+	flush   func() error
+	latency time.Duration
+
+	mu           sync.Mutex
+	timer        *time.Timer
+	flushPending bool
+}
+
+func (m *maxLatencyWriter) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, err := m.dst.Write(p)
+	if m.latency < 0 {
+		m.flush()
+		return n, err
+	}
+	if m.flushPending {
+		return n, err
+	}
+	if m.timer == nil {
+		m.timer = time.AfterFunc(m.latency, m.delayedFlush)
+	} else {
+		m.timer.Reset(m.latency)
+	}
+	m.flushPending = true
+	return n, err
+}
+
+func (m *maxLatencyWriter) delayedFlush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Запись уже завершилась через stop
+	if !m.flushPending {
+		return
+	}
+	m.flush()
+	m.flushPending = false
+}
+
+func (m *maxLatencyWriter) stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.flushPending = false
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type HealthCheckConfig struct {
+	Path     string
+	Interval time.Duration
+	Timeout  time.Duration
+	// Сколько проверок подряд должно провалиться, чтобы исключить экземпляр
+	UnhealthyThreshold int
+	// Сколько проверок подряд должно пройти, чтобы вернуть экземпляр
+	HealthyThreshold int
+}
+
+type healthChecker struct {
+	cfg    HealthCheckConfig
+	client *http.Client
+}
+
+func newHealthChecker(cfg HealthCheckConfig) *healthChecker {
+	if cfg.Path == "" {
+		cfg.Path = "/health"
+	}
+	if cfg.UnhealthyThreshold < 1 {
+		cfg.UnhealthyThreshold = 1
+	}
+	if cfg.HealthyThreshold < 1 {
+		cfg.HealthyThreshold = 1
+	}
+	return &healthChecker{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// run периодически опрашивает экземпляры всех upstream'ов, пока не отменён ctx
+func (hc *healthChecker) run(ctx context.Context, instances func() []*Instance) {
+	if hc.cfg.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(hc.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		hc.checkAll(ctx, instances())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (hc *healthChecker) checkAll(ctx context.Context, instances []*Instance) {
+	var wg sync.WaitGroup
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance *Instance) {
+			defer wg.Done()
+			hc.update(instance, hc.probe(ctx, instance))
+		}(instance)
+	}
+	wg.Wait()
+}
+
+func (hc *healthChecker) probe(ctx context.Context, instance *Instance) error {
+	target := *instance.URL
+	target.Path = hc.cfg.Path
+	target.RawQuery = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("health check responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (hc *healthChecker) update(instance *Instance, err error) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	if err != nil {
+		instance.consecutiveSuccesses = 0
+		instance.consecutiveFailures++
+		instance.lastError = err.Error()
+		if instance.Healthy() && instance.consecutiveFailures >= hc.cfg.UnhealthyThreshold {
+			instance.healthy.Store(false)
+			log.Printf("Instance %s ejected: %v", instance.URL, err)
+		}
+		return
+	}
+
+	instance.consecutiveFailures = 0
+	instance.consecutiveSuccesses++
+	if !instance.Healthy() && instance.consecutiveSuccesses >= hc.cfg.HealthyThreshold {
+		instance.healthy.Store(true)
+		instance.lastError = ""
+		log.Printf("Instance %s re-admitted", instance.URL)
+	}
+}
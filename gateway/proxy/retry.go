@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+type RetryPolicy struct {
+	// Общее число попыток, включая первую
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// backoff возвращает паузу перед повторной попыткой (exponential backoff с full jitter)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+func (p RetryPolicy) attempts(r *http.Request) int {
+	if p.MaxAttempts < 1 || !canRetry(r) {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// canRetry разрешает повтор только для идемпотентных методов без тела:
+// тело запроса передаётся потоком и повторно прочитать его нельзя
+func canRetry(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return r.ContentLength == 0
+	default:
+		return false
+	}
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
@@ -1,105 +1,409 @@
 package proxy
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"math"
+	"mime"
+	"net"
 	"net/http"
-	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Интервал сброса буфера для ответов без Content-Length
+const defaultFlushInterval = 100 * time.Millisecond
+
+// Hop-by-hop заголовки (RFC 7230, раздел 6.1) не передаются между соединениями
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+type UpstreamConfig struct {
+	Instances []InstanceConfig
+	Strategy  string
+}
+
+type Config struct {
+	UserService           UpstreamConfig
+	OrderService          UpstreamConfig
+	DialTimeout           time.Duration
+	ResponseHeaderTimeout time.Duration
+	Breaker               BreakerConfig
+	Retry                 RetryPolicy
+	HealthCheck           HealthCheckConfig
+}
+
 type ReverseProxy struct {
-	userServiceURL  string
-	orderServiceURL string
-	client          *http.Client
+	userService   *upstream
+	orderService  *upstream
+	client        *http.Client
+	retry         RetryPolicy
+	healthChecker *healthChecker
+	flushInterval time.Duration
 }
 
-func NewReverseProxy(userServiceURL, orderServiceURL string) *ReverseProxy {
+type UpstreamStatus struct {
+	Strategy  string           `json:"strategy"`
+	Instances []InstanceStatus `json:"instances"`
+}
+
+func NewReverseProxy(cfg Config) (*ReverseProxy, error) {
+	userService, err := newUpstream("user-service", cfg.UserService, cfg.Breaker)
+	if err != nil {
+		return nil, err
+	}
+	orderService, err := newUpstream("order-service", cfg.OrderService, cfg.Breaker)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Недоступный upstream должен обнаруживаться быстро, а не через общий таймаут
+	transport.DialContext = (&net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	// Ограничиваем только ожидание заголовков: тело ответа может стримиться сколько угодно долго
+	transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+
 	return &ReverseProxy{
-		userServiceURL:  userServiceURL,
-		orderServiceURL: orderServiceURL,
+		userService:  userService,
+		orderService: orderService,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Transport: transport,
+			// Редиректы отдаём клиенту как есть
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
 		},
+		retry:         cfg.Retry,
+		healthChecker: newHealthChecker(cfg.HealthCheck),
+		flushInterval: defaultFlushInterval,
+	}, nil
+}
+
+func (p *ReverseProxy) Handler(name string) http.Handler {
+	for _, up := range p.upstreams() {
+		if up.name == name {
+			up := up
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				p.proxy(w, r, up)
+			})
+		}
 	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithError(w, http.StatusBadGateway, "PROXY_ERROR", "unknown upstream "+name)
+	})
+}
+
+// Upstreams возвращает имена известных upstream-сервисов
+func (p *ReverseProxy) Upstreams() []string {
+	var names []string
+	for _, up := range p.upstreams() {
+		names = append(names, up.name)
+	}
+	return names
 }
 
 func (p *ReverseProxy) ProxyToUserService(w http.ResponseWriter, r *http.Request) {
-	p.proxy(w, r, p.userServiceURL)
+	p.proxy(w, r, p.userService)
 }
 
 func (p *ReverseProxy) ProxyToOrderService(w http.ResponseWriter, r *http.Request) {
-	p.proxy(w, r, p.orderServiceURL)
+	p.proxy(w, r, p.orderService)
+}
+
+// StartHealthChecks запускает активную проверку экземпляров до отмены ctx
+func (p *ReverseProxy) StartHealthChecks(ctx context.Context) {
+	go p.healthChecker.run(ctx, func() []*Instance {
+		var instances []*Instance
+		for _, up := range p.upstreams() {
+			instances = append(instances, up.pool.Load().instances...)
+		}
+		return instances
+	})
+}
+
+// Status возвращает состояние upstream-сервисов для /health
+func (p *ReverseProxy) Status() map[string]UpstreamStatus {
+	status := make(map[string]UpstreamStatus)
+	for _, up := range p.upstreams() {
+		pool := up.pool.Load()
+		instances := make([]InstanceStatus, 0, len(pool.instances))
+		for _, instance := range pool.instances {
+			instances = append(instances, instance.status())
+		}
+		status[up.name] = UpstreamStatus{
+			Strategy:  pool.strategy,
+			Instances: instances,
+		}
+	}
+	return status
 }
 
-func (p *ReverseProxy) proxy(w http.ResponseWriter, r *http.Request, targetURL string) {
-	// Создаём URL для целевого сервиса
-	target, err := url.Parse(targetURL)
+// PrepareUpdate проверяет новый состав экземпляров и возвращает функцию, атомарно применяющую его.
+// Запросы, уже выбравшие экземпляр, завершаются на нём; состояние предохранителей сохраняется.
+func (p *ReverseProxy) PrepareUpdate(cfg Config) (func(), error) {
+	userPool, err := p.userService.preparePool(cfg.UserService)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "PROXY_ERROR", "failed to parse target URL")
+		return nil, err
+	}
+	orderPool, err := p.orderService.preparePool(cfg.OrderService)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		p.userService.pool.Store(userPool)
+		p.orderService.pool.Store(orderPool)
+	}, nil
+}
+
+func (p *ReverseProxy) upstreams() []*upstream {
+	return []*upstream{p.userService, p.orderService}
+}
+
+func (p *ReverseProxy) proxy(w http.ResponseWriter, r *http.Request, up *upstream) {
+	attempts := p.retry.attempts(r)
+	tried := make(map[*Instance]bool)
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if !sleepContext(r.Context(), p.retry.backoff(attempt-1)) {
+				respondOnContextDone(w, r)
+				return
+			}
+		}
+
+		instance, done := up.acquire(tried)
+		if instance == nil {
+			// Все экземпляры исключены health check'ом или отключены своими предохранителями
+			message, wait := up.unavailable()
+			if retryAfter := int(math.Ceil(wait.Seconds())); retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			}
+			respondWithError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", message)
+			return
+		}
+		tried[instance] = true
+
+		instance.activeConns.Add(1)
+		resp, err := p.roundTrip(r, instance)
+		if err != nil {
+			instance.activeConns.Add(-1)
+			if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+				// Истёк таймаут маршрута — upstream не успел ответить
+				done(resultFailure)
+				instance.breaker.RecordError(err)
+				respondOnContextDone(w, r)
+				return
+			}
+			if r.Context().Err() != nil {
+				// Клиент ушёл сам, отвечать некому
+				done(resultIgnored)
+				log.Printf("Proxy request cancelled by client: %v", err)
+				return
+			}
+			done(resultFailure)
+			instance.breaker.RecordError(err)
+			log.Printf("Proxy error (%s, attempt %d/%d): %v", instance.URL, attempt, attempts, err)
+			continue
+		}
+
+		if isRetryableStatus(resp.StatusCode) {
+			done(resultFailure)
+			instance.breaker.RecordError(fmt.Errorf("%s responded with status %d", instance.URL, resp.StatusCode))
+			if attempt < attempts {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				instance.activeConns.Add(-1)
+				continue
+			}
+		} else {
+			done(resultSuccess)
+		}
+
+		p.writeResponse(w, resp)
+		instance.activeConns.Add(-1)
 		return
 	}
 
+	respondWithError(w, http.StatusBadGateway, "SERVICE_UNAVAILABLE", "target service is unavailable")
+}
+
+func (p *ReverseProxy) roundTrip(r *http.Request, instance *Instance) (*http.Response, error) {
 	// Копируем оригинальный путь и query параметры
+	target := *instance.URL
 	target.Path = r.URL.Path
+	target.RawPath = r.URL.RawPath
 	target.RawQuery = r.URL.RawQuery
 
-	// Читаем тело запроса
-	var bodyBytes []byte
-	if r.Body != nil {
-		bodyBytes, err = io.ReadAll(r.Body)
-		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "failed to read request body")
-			return
-		}
-		r.Body.Close()
+	// Тело запроса передаётся потоком, без буферизации в памяти шлюза
+	body := r.Body
+	if r.ContentLength == 0 {
+		body = nil
 	}
 
-	// Создаём новый запрос
-	proxyReq, err := http.NewRequest(r.Method, target.String(), bytes.NewReader(bodyBytes))
+	proxyReq, err := http.NewRequestWithContext(r.Context(), r.Method, target.String(), body)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "PROXY_ERROR", "failed to create proxy request")
+		return nil, err
+	}
+	proxyReq.ContentLength = r.ContentLength
+
+	copyHeaders(proxyReq.Header, r.Header)
+	removeHopByHopHeaders(proxyReq.Header)
+	setForwardedHeaders(proxyReq, r)
+
+	// Логируем запрос
+	log.Printf("Proxying %s %s → %s", r.Method, r.URL.Path, target.String())
+
+	return p.client.Do(proxyReq)
+}
+
+func (p *ReverseProxy) writeResponse(w http.ResponseWriter, resp *http.Response) {
+	defer resp.Body.Close()
+
+	removeHopByHopHeaders(resp.Header)
+	copyHeaders(w.Header(), resp.Header)
+
+	// Анонсируем трейлеры, чтобы передать их после тела
+	if len(resp.Trailer) > 0 {
+		trailerKeys := make([]string, 0, len(resp.Trailer))
+		for key := range resp.Trailer {
+			trailerKeys = append(trailerKeys, key)
+		}
+		w.Header().Set("Trailer", strings.Join(trailerKeys, ", "))
+	}
+
+	w.WriteHeader(resp.StatusCode)
+
+	if err := p.copyResponse(w, resp); err != nil {
+		log.Printf("Proxy response copy error: %v", err)
 		return
 	}
 
-	// Копируем все заголовки
-	for key, values := range r.Header {
+	for key, values := range resp.Trailer {
 		for _, value := range values {
-			proxyReq.Header.Add(key, value)
+			w.Header().Add(key, value)
 		}
 	}
+}
 
-	// Добавляем X-Forwarded-For
-	proxyReq.Header.Set("X-Forwarded-For", r.RemoteAddr)
-	proxyReq.Header.Set("X-Forwarded-Host", r.Host)
-	proxyReq.Header.Set("X-Forwarded-Proto", r.URL.Scheme)
+func respondOnContextDone(w http.ResponseWriter, r *http.Request) {
+	if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		respondWithError(w, http.StatusGatewayTimeout, "GATEWAY_TIMEOUT", "upstream did not respond in time")
+	}
+}
 
-	// Логируем запрос
-	log.Printf("Proxying %s %s → %s", r.Method, r.URL.Path, target.String())
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
 
-	// Выполняем запрос
-	resp, err := p.client.Do(proxyReq)
-	if err != nil {
-		log.Printf("Proxy error: %v", err)
-		respondWithError(w, http.StatusBadGateway, "SERVICE_UNAVAILABLE", "target service is unavailable")
-		return
+// copyResponse передаёт тело ответа клиенту по мере поступления.
+// Потоковые ответы (SSE, chunked) сбрасываются сразу после каждой записи,
+// остальные — не реже чем раз в flushInterval.
+func (p *ReverseProxy) copyResponse(w http.ResponseWriter, resp *http.Response) error {
+	rc := http.NewResponseController(w)
+
+	flushInterval := p.flushInterval
+	if isStreamingResponse(resp) {
+		flushInterval = -1
 	}
-	defer resp.Body.Close()
 
-	// Копируем заголовки ответа
-	for key, values := range resp.Header {
+	var dst io.Writer = w
+	if flushInterval != 0 {
+		mlw := &maxLatencyWriter{
+			dst:     w,
+			flush:   rc.Flush,
+			latency: flushInterval,
+		}
+		defer mlw.stop()
+		dst = mlw
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+func isStreamingResponse(resp *http.Response) bool {
+	if resp.ContentLength == -1 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+func copyHeaders(dst, src http.Header) {
+	for key, values := range src {
 		for _, value := range values {
-			w.Header().Add(key, value)
+			dst.Add(key, value)
 		}
 	}
+}
 
-	// Устанавливаем статус код
-	w.WriteHeader(resp.StatusCode)
+func removeHopByHopHeaders(h http.Header) {
+	// Заголовки, перечисленные в Connection, тоже hop-by-hop
+	for _, value := range h.Values("Connection") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				h.Del(field)
+			}
+		}
+	}
+	for _, header := range hopByHopHeaders {
+		h.Del(header)
+	}
+}
+
+func setForwardedHeaders(proxyReq, r *http.Request) {
+	// Дописываем адрес клиента в конец цепочки X-Forwarded-For
+	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := r.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+			clientIP = strings.Join(prior, ", ") + ", " + clientIP
+		}
+		proxyReq.Header.Set("X-Forwarded-For", clientIP)
+	}
 
-	// Копируем тело ответа
-	io.Copy(w, resp.Body)
+	proxyReq.Header.Set("X-Forwarded-Host", r.Host)
+	if r.TLS != nil {
+		proxyReq.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		proxyReq.Header.Set("X-Forwarded-Proto", "http")
+	}
 }
 
 func respondWithError(w http.ResponseWriter, status int, code, message string) {
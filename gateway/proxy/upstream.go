@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type upstream struct {
+	name string
+	pool atomic.Pointer[upstreamPool]
+	// Настройки предохранителей экземпляров
+	breakerCfg BreakerConfig
+}
+
+// upstreamPool — неизменяемый набор экземпляров; при перезагрузке конфигурации заменяется целиком
+type upstreamPool struct {
+	strategy  string
+	instances []*Instance
+	balancer  Balancer
+}
+
+func newUpstream(name string, cfg UpstreamConfig, breakerCfg BreakerConfig) (*upstream, error) {
+	up := &upstream{
+		name:       name,
+		breakerCfg: breakerCfg,
+	}
+
+	pool, err := up.preparePool(cfg)
+	if err != nil {
+		return nil, err
+	}
+	up.pool.Store(pool)
+
+	return up, nil
+}
+
+// preparePool собирает новый набор экземпляров, переиспользуя уже известные:
+// так сохраняются результаты health check'ов, предохранители и счётчики соединений
+func (u *upstream) preparePool(cfg UpstreamConfig) (*upstreamPool, error) {
+	if len(cfg.Instances) == 0 {
+		return nil, fmt.Errorf("%s: no instances configured", u.name)
+	}
+
+	balancer, err := NewBalancer(cfg.Strategy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u.name, err)
+	}
+
+	existing := make(map[string]*Instance)
+	if current := u.pool.Load(); current != nil {
+		for _, instance := range current.instances {
+			existing[instance.URL.String()] = instance
+		}
+	}
+
+	instances := make([]*Instance, 0, len(cfg.Instances))
+	for _, instanceCfg := range cfg.Instances {
+		instance, err := newInstance(instanceCfg, u.breakerCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", u.name, err)
+		}
+		if known, ok := existing[instance.URL.String()]; ok && known.Weight == instance.Weight {
+			instance = known
+		}
+		instances = append(instances, instance)
+	}
+
+	strategy := cfg.Strategy
+	if strategy == "" {
+		strategy = StrategyRoundRobin
+	}
+
+	return &upstreamPool{
+		strategy:  strategy,
+		instances: instances,
+		balancer:  balancer,
+	}, nil
+}
+
+// acquire выбирает экземпляр и занимает место в его предохранителе.
+// Возвращённую функцию нужно вызвать ровно один раз с результатом запроса.
+func (u *upstream) acquire(tried map[*Instance]bool) (*Instance, func(breakerResult)) {
+	// Предохранитель мог разомкнуться между выбором и Allow — тогда берём другой экземпляр
+	skipped := make(map[*Instance]bool)
+	for {
+		instance := u.pick(tried, skipped)
+		if instance == nil {
+			return nil, nil
+		}
+		done, err := instance.breaker.Allow()
+		if err == nil {
+			return instance, done
+		}
+		skipped[instance] = true
+	}
+}
+
+// pick выбирает здоровый экземпляр, предохранитель которого пропускает запросы,
+// по возможности ещё не опробованный в этом запросе
+func (u *upstream) pick(tried, skipped map[*Instance]bool) *Instance {
+	pool := u.pool.Load()
+
+	var healthy, untried []*Instance
+	for _, instance := range pool.instances {
+		if !instance.Healthy() || skipped[instance] || !instance.breaker.Ready() {
+			continue
+		}
+		healthy = append(healthy, instance)
+		if !tried[instance] {
+			untried = append(untried, instance)
+		}
+	}
+
+	if len(untried) > 0 {
+		return pool.balancer.Next(untried)
+	}
+	if len(healthy) > 0 {
+		return pool.balancer.Next(healthy)
+	}
+	return nil
+}
+
+// unavailable объясняет, почему acquire не нашёл экземпляр, и через сколько стоит повторить запрос
+func (u *upstream) unavailable() (string, time.Duration) {
+	var retryAfter time.Duration
+	healthy := false
+	for _, instance := range u.pool.Load().instances {
+		if !instance.Healthy() {
+			continue
+		}
+		healthy = true
+		if wait := instance.breaker.RetryAfter(); retryAfter == 0 || (wait > 0 && wait < retryAfter) {
+			retryAfter = wait
+		}
+	}
+
+	if !healthy {
+		return "no healthy " + u.name + " instances", 0
+	}
+	return u.name + " is temporarily unavailable", retryAfter
+}
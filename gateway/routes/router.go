@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Middleware func(http.Handler) http.Handler
+
+// Dependencies связывает таблицу маршрутов с компонентами шлюза
+type Dependencies struct {
+	Upstream func(name string) http.Handler
+	// Лимит по IP, который действует до проверки JWT
+	IPRateLimit Middleware
+	JWTAuth     Middleware
+	Admin       Middleware
+	RateLimit   func(group string, limit *RateLimit) Middleware
+	Quota       Middleware
+	Timeout     func(timeout time.Duration) Middleware
+	Identity    Middleware
+}
+
+// Mount регистрирует маршруты таблицы в роутере. Таблица должна быть проверена через Validate.
+// При вложенных префиксах запрос обслуживает самый длинный префикс, у которого есть метод
+// запроса; если такого нет, маршрут с более коротким префиксом. Его авторизация, лимиты
+// и upstream применяются целиком.
+func (t *Table) Mount(r chi.Router, deps Dependencies) {
+	for _, route := range t.Routes {
+		// Лимит по IP первым: запросы с недействительными токенами не нагружают проверку JWT
+		chain := []func(http.Handler) http.Handler{deps.IPRateLimit}
+
+		switch route.Auth {
+		case AuthJWT:
+			chain = append(chain, deps.JWTAuth)
+		case AuthAdmin:
+			chain = append(chain, deps.JWTAuth, deps.Admin)
+		}
+		// Лимит после авторизации: так запросы считаются по пользователю, а не по IP
+		chain = append(chain, deps.RateLimit(route.RateLimitGroup(), route.RateLimit))
+		// Квота учитывает только запросы, прошедшие rate limiting
+		chain = append(chain, deps.Quota)
+		if route.Timeout > 0 {
+			chain = append(chain, deps.Timeout(time.Duration(route.Timeout)))
+		}
+		chain = append(chain, deps.Identity)
+
+		handler := deps.Upstream(route.Upstream)
+		router := r.With(chain...)
+
+		// Префикс обслуживает и сам путь, и всё, что под ним
+		patterns := []string{route.Path, strings.TrimSuffix(route.Path, "/") + "/*"}
+		for _, pattern := range patterns {
+			if len(route.Methods) == 0 {
+				router.Handle(pattern, handler)
+				continue
+			}
+			for _, method := range route.Methods {
+				router.Method(method, pattern, handler)
+			}
+		}
+
+		methods := "*"
+		if len(route.Methods) > 0 {
+			methods = strings.Join(route.Methods, ",")
+		}
+		log.Printf("Route %s %s → %s (auth: %s)", methods, route.Path, route.Upstream, route.Auth)
+	}
+}
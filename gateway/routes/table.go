@@ -0,0 +1,232 @@
+package routes
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	AuthPublic = "public"
+	AuthJWT    = "jwt"
+	AuthAdmin  = "admin"
+)
+
+// Таблица по умолчанию, если ROUTES_FILE не задан
+//
+//go:embed routes.yaml
+var defaultTable []byte
+
+var supportedMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+type Table struct {
+	Routes []Route `yaml:"routes"`
+}
+
+type Route struct {
+	Path      string     `yaml:"path"`
+	Methods   []string   `yaml:"methods"`
+	Upstream  string     `yaml:"upstream"`
+	Auth      string     `yaml:"auth"`
+	Timeout   Duration   `yaml:"timeout"`
+	RateLimit *RateLimit `yaml:"rate_limit"`
+}
+
+type RateLimit struct {
+	// Маршруты одной группы делят общий счётчик; по умолчанию группа — путь маршрута
+	Group string `yaml:"group"`
+	RPS   int    `yaml:"rps"`
+	Burst int    `yaml:"burst"`
+}
+
+// Duration читается из строки вида "10s"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var raw string
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	if raw == "" {
+		*d = 0
+		return nil
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", raw)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Load читает таблицу из YAML или JSON файла; пустой путь — встроенная таблица
+func Load(path string) (*Table, error) {
+	if path == "" {
+		return Parse(defaultTable)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routes file: %w", err)
+	}
+
+	table, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return table, nil
+}
+
+// Parse разбирает таблицу маршрутов. JSON является подмножеством YAML,
+// поэтому оба формата читаются одним парсером.
+func Parse(data []byte) (*Table, error) {
+	var table Table
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&table); err != nil {
+		return nil, fmt.Errorf("failed to parse routes: %w", err)
+	}
+
+	for i := range table.Routes {
+		route := &table.Routes[i]
+		route.Path = normalizePath(route.Path)
+		for j, method := range route.Methods {
+			route.Methods[j] = strings.ToUpper(strings.TrimSpace(method))
+		}
+	}
+	return &table, nil
+}
+
+// Validate проверяет таблицу и возвращает все найденные ошибки сразу
+func (t *Table) Validate(upstreams []string) error {
+	knownUpstreams := make(map[string]bool)
+	for _, name := range upstreams {
+		knownUpstreams[name] = true
+	}
+
+	var errs []error
+	groupLimits := make(map[string]*RateLimit)
+	if len(t.Routes) == 0 {
+		errs = append(errs, errors.New("route table is empty"))
+	}
+
+	for i, route := range t.Routes {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, fmt.Errorf("route #%d (%s): %s", i+1, route.Path, fmt.Sprintf(format, args...)))
+		}
+
+		if route.Path == "" || !strings.HasPrefix(route.Path, "/") {
+			fail("path must start with /")
+		}
+		if strings.ContainsAny(route.Path, "*{}") {
+			fail("path must be a plain prefix without wildcards or parameters")
+		}
+		for _, method := range route.Methods {
+			if !isSupportedMethod(method) {
+				fail("unsupported method %q", method)
+			}
+		}
+		if route.Upstream == "" {
+			fail("upstream is required")
+		} else if !knownUpstreams[route.Upstream] {
+			fail("unknown upstream %q (known: %s)", route.Upstream, strings.Join(upstreams, ", "))
+		}
+		switch route.Auth {
+		case AuthPublic, AuthJWT, AuthAdmin:
+		default:
+			fail("auth must be one of %s, %s, %s; got %q", AuthPublic, AuthJWT, AuthAdmin, route.Auth)
+		}
+		if route.Timeout < 0 {
+			fail("timeout cannot be negative")
+		}
+		if route.RateLimit != nil {
+			if route.RateLimit.RPS <= 0 || route.RateLimit.Burst <= 0 {
+				fail("rate_limit rps and burst must be greater than 0")
+			}
+			group := route.RateLimitGroup()
+			if other, exists := groupLimits[group]; exists &&
+				(other.RPS != route.RateLimit.RPS || other.Burst != route.RateLimit.Burst) {
+				fail("rate limit group %q is already defined with rps %d and burst %d", group, other.RPS, other.Burst)
+			} else if !exists {
+				groupLimits[group] = route.RateLimit
+			}
+		}
+
+		// Два маршрута с одинаковым префиксом не должны делить методы. Вложенные префиксы
+		// допустимы: из них по каждому методу побеждает самый длинный (см. Mount).
+		for j := 0; j < i; j++ {
+			other := t.Routes[j]
+			if other.Path != route.Path {
+				continue
+			}
+			if shared := sharedMethods(route.Methods, other.Methods); len(shared) > 0 {
+				fail("overlaps with route #%d on %s", j+1, strings.Join(shared, ", "))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RateLimitGroup возвращает имя группы, в которой считаются запросы маршрута
+func (r Route) RateLimitGroup() string {
+	if r.RateLimit != nil && r.RateLimit.Group != "" {
+		return r.RateLimit.Group
+	}
+	return r.Path
+}
+
+func normalizePath(path string) string {
+	path = strings.TrimSpace(path)
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	return path
+}
+
+// sharedMethods возвращает пересечение методов; пустой список означает все методы
+func sharedMethods(a, b []string) []string {
+	if len(a) == 0 {
+		a = supportedMethods
+	}
+	if len(b) == 0 {
+		b = supportedMethods
+	}
+
+	inB := make(map[string]bool)
+	for _, method := range b {
+		inB[method] = true
+	}
+
+	var shared []string
+	for _, method := range a {
+		if inB[method] {
+			shared = append(shared, method)
+		}
+	}
+	return shared
+}
+
+func isSupportedMethod(method string) bool {
+	for _, supported := range supportedMethods {
+		if method == supported {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var testUpstreams = []string{"user-service", "order-service", "report-service"}
+
+func TestDefaultTableIsValid(t *testing.T) {
+	table, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := table.Validate([]string{"user-service", "order-service"}); err != nil {
+		t.Fatalf("default table is invalid: %v", err)
+	}
+}
+
+func TestValidateOverlaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  []Route
+		wantErr string
+	}{
+		{
+			name: "same prefix with shared methods",
+			routes: []Route{
+				{Path: "/api/v1/orders", Methods: []string{"GET", "POST"}, Upstream: "order-service", Auth: AuthJWT},
+				{Path: "/api/v1/orders", Methods: []string{"POST"}, Upstream: "report-service", Auth: AuthJWT},
+			},
+			wantErr: "overlaps with route #1 on POST",
+		},
+		{
+			name: "same prefix, one route without methods",
+			routes: []Route{
+				{Path: "/api/v1/orders", Upstream: "order-service", Auth: AuthJWT},
+				{Path: "/api/v1/orders/", Methods: []string{"DELETE"}, Upstream: "order-service", Auth: AuthAdmin},
+			},
+			wantErr: "overlaps with route #1 on DELETE",
+		},
+		{
+			name: "same prefix with disjoint methods",
+			routes: []Route{
+				{Path: "/api/v1/orders", Methods: []string{"GET"}, Upstream: "order-service", Auth: AuthJWT},
+				{Path: "/api/v1/orders", Methods: []string{"DELETE"}, Upstream: "order-service", Auth: AuthAdmin},
+			},
+		},
+		{
+			name: "nested prefixes with different upstreams and methods",
+			routes: []Route{
+				{Path: "/api/v1/orders", Methods: []string{"GET", "POST"}, Upstream: "order-service", Auth: AuthJWT},
+				{Path: "/api/v1/orders/export", Methods: []string{"GET"}, Upstream: "report-service", Auth: AuthAdmin},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := parseRoutes(t, tt.routes)
+			err := table.Validate(testUpstreams)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMountMostSpecificRouteWins(t *testing.T) {
+	table := parseRoutes(t, []Route{
+		{Path: "/api/v1/orders", Methods: []string{"GET", "POST"}, Upstream: "order-service", Auth: AuthJWT},
+		{Path: "/api/v1/orders/export", Methods: []string{"GET"}, Upstream: "report-service", Auth: AuthAdmin},
+		{Path: "/api/v1/orders/export/archive", Methods: []string{"GET"}, Upstream: "order-service", Auth: AuthPublic},
+	})
+	if err := table.Validate(testUpstreams); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	r := chi.NewRouter()
+	table.Mount(r, testDependencies())
+
+	tests := []struct {
+		method, path string
+		wantStatus   int
+		// upstream и цепочка middleware маршрута, который обслужил запрос
+		wantUpstream, wantChain string
+	}{
+		{"GET", "/api/v1/orders", http.StatusOK, "order-service", "ip,jwt,limit:/api/v1/orders"},
+		{"GET", "/api/v1/orders/123", http.StatusOK, "order-service", "ip,jwt,limit:/api/v1/orders"},
+		{"GET", "/api/v1/orders/export", http.StatusOK, "report-service", "ip,jwt,admin,limit:/api/v1/orders/export"},
+		{"GET", "/api/v1/orders/export/2024", http.StatusOK, "report-service", "ip,jwt,admin,limit:/api/v1/orders/export"},
+		{"GET", "/api/v1/orders/export/archive/1", http.StatusOK, "order-service", "ip,limit:/api/v1/orders/export/archive"},
+		// Префикс сравнивается по сегментам пути
+		{"GET", "/api/v1/orders/exports", http.StatusOK, "order-service", "ip,jwt,limit:/api/v1/orders"},
+		// У вложенного маршрута нет POST — запрос обслуживает родительский
+		{"POST", "/api/v1/orders/export", http.StatusOK, "order-service", "ip,jwt,limit:/api/v1/orders"},
+		{"DELETE", "/api/v1/orders/export", http.StatusMethodNotAllowed, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Body.String(); got != tt.wantUpstream {
+				t.Errorf("upstream = %q, want %q", got, tt.wantUpstream)
+			}
+			if got := strings.Join(w.Header().Values("X-Chain"), ","); got != tt.wantChain {
+				t.Errorf("chain = %q, want %q", got, tt.wantChain)
+			}
+		})
+	}
+}
+
+// parseRoutes прогоняет маршруты через Parse, чтобы пути и методы нормализовались как при загрузке
+func parseRoutes(t *testing.T, routes []Route) *Table {
+	t.Helper()
+
+	var b strings.Builder
+	b.WriteString("routes:\n")
+	for _, route := range routes {
+		b.WriteString("  - path: " + route.Path + "\n")
+		if len(route.Methods) > 0 {
+			b.WriteString("    methods: [" + strings.Join(route.Methods, ", ") + "]\n")
+		}
+		b.WriteString("    upstream: " + route.Upstream + "\n")
+		b.WriteString("    auth: " + route.Auth + "\n")
+	}
+
+	table, err := Parse([]byte(b.String()))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return table
+}
+
+// testDependencies отмечает каждый пройденный middleware в заголовке X-Chain,
+// а upstream пишет в тело своё имя
+func testDependencies() Dependencies {
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Chain", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	pass := func(next http.Handler) http.Handler { return next }
+
+	return Dependencies{
+		Upstream: func(name string) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(name))
+			})
+		},
+		IPRateLimit: mark("ip"),
+		JWTAuth:     mark("jwt"),
+		Admin:       mark("admin"),
+		RateLimit: func(group string, limit *RateLimit) Middleware {
+			return mark("limit:" + group)
+		},
+		Quota:    pass,
+		Timeout:  func(time.Duration) Middleware { return pass },
+		Identity: pass,
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"log"
 	"net/http"
 	"order-service/internal/events"
@@ -8,11 +10,18 @@ import (
 	"order-service/internal/middleware"
 	"order-service/internal/repository"
 	"order-service/internal/service"
+	"order-service/migrations"
 	"os"
+	"time"
 
+	"github.com/ChrolloLucii/control-system/shared/cursor"
+	"github.com/ChrolloLucii/control-system/shared/jwks"
+	"github.com/ChrolloLucii/control-system/shared/migrate"
+	"github.com/ChrolloLucii/control-system/shared/revocation"
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
+	_ "modernc.org/sqlite"
 )
 
 func main() {
@@ -21,18 +30,60 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
-	// JWT Secret
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "your-super-secret-jwt-key-change-in-production-12345"
+	// order-service migrate up|down [steps]|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate.Command(context.Background(), newMigrator(openOrderDB()), os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
 	}
 
+	// Публичные ключи user-service для проверки JWT
+	jwksURL := os.Getenv("JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = "http://localhost:3001/.well-known/jwks.json"
+	}
+	jwksClient := jwks.NewClient(jwksURL, 5*time.Minute)
+	if err := jwksClient.Refresh(context.Background()); err != nil {
+		// Ключи будут загружены при первом запросе
+		log.Printf("JWKS is not available yet: %v", err)
+	}
+
+	// Список отозванных токенов user-service, обновляется long polling'ом
+	revocationsURL := os.Getenv("REVOCATIONS_URL")
+	if revocationsURL == "" {
+		revocationsURL = "http://localhost:3001/internal/revocations"
+	}
+	revocations := revocation.NewCache(revocationsURL)
+	if err := revocations.Sync(context.Background()); err != nil {
+		log.Printf("Token revocation list is not available yet: %v", err)
+	}
+	go revocations.Run(context.Background())
+
+	// Ключ шлюза для проверки X-Internal-Identity
+	gatewayJWKSURL := os.Getenv("GATEWAY_JWKS_URL")
+	if gatewayJWKSURL == "" {
+		gatewayJWKSURL = "http://localhost:8080/.well-known/gateway-jwks.json"
+	}
+	gatewayKeys := jwks.NewClient(gatewayJWKSURL, 5*time.Minute)
+
 	// Инициализация зависимостей
-	orderRepo := repository.NewInMemoryOrderRepository()
-	eventPublisher := events.NewInMemoryEventPublisher()
+	repos := newRepositories()
+	eventPublisher := newEventPublisher()
 	userClient := service.NewHTTPUserClient()
-	orderService := service.NewOrderService(orderRepo, eventPublisher, userClient)
-	orderHandler := handlers.NewOrderHandler(orderService)
+	catalogClient := service.NewLocalCatalogClient(repos.products)
+	orderService := service.NewOrderService(repos.orders, userClient, catalogClient, repos.inventory,
+		getEnvDuration("RESERVATION_TTL", 30*time.Minute))
+	// События заказов уходят из outbox: публикуются только сохранённые изменения
+	outboxRelay := service.NewOutboxRelay(repos.outbox, eventPublisher, getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second))
+	go outboxRelay.Run(context.Background())
+	go service.RunOutboxCleanup(context.Background(), repos.outbox, getEnvDuration("OUTBOX_RETENTION", 24*time.Hour))
+	productService := service.NewProductService(repos.products, repos.inventory)
+	// Неподтверждённые резервы освобождаются, их заказы отменяются
+	go service.RunReservationExpiry(context.Background(), orderService, getEnvDuration("RESERVATION_SWEEP_INTERVAL", time.Minute))
+	// Подпись курсоров пагинации; у всех реплик должна быть одна
+	orderHandler := handlers.NewOrderHandler(orderService, cursor.NewCodec(os.Getenv("CURSOR_SECRET")))
+	productHandler := handlers.NewProductHandler(productService)
 
 	// Настройка роутера
 	r := chi.NewRouter()
@@ -44,7 +95,11 @@ func main() {
 	r.Use(middleware.CORSMiddleware)
 
 	// Регистрация роутов
-	orderHandler.RegisterRoutes(r, jwtSecret)
+	auth := middleware.AuthMiddleware(jwksClient, revocations, gatewayKeys)
+	idempotency := middleware.IdempotencyMiddleware(repos.idempotency, getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour))
+	go middleware.RunIdempotencyCleanup(context.Background(), repos.idempotency, getEnvDuration("IDEMPOTENCY_CLEANUP_INTERVAL", time.Hour))
+	orderHandler.RegisterRoutes(r, auth, idempotency)
+	productHandler.RegisterRoutes(r, auth)
 
 	// Health check
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -52,6 +107,7 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"ok","service":"order-service"}`))
 	})
+	r.Get("/metrics", handlers.MetricsHandler(repos.outbox, outboxRelay))
 
 	// Запуск сервера
 	port := os.Getenv("PORT")
@@ -64,3 +120,128 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+type repositories struct {
+	orders      repository.OrderRepository
+	products    repository.ProductRepository
+	inventory   repository.InventoryRepository
+	idempotency repository.IdempotencyRepository
+	outbox      repository.OutboxRepository
+}
+
+// newRepositories выбирает хранилища по ORDER_STORE: sqlite (по умолчанию) или memory
+func newRepositories() repositories {
+	switch store := orderStore(); store {
+	case "memory":
+		outbox := repository.NewInMemoryOutboxRepository()
+		inventory := repository.NewInMemoryInventoryRepository()
+		return repositories{
+			orders:      repository.NewInMemoryOrderRepository(outbox, inventory),
+			products:    repository.NewInMemoryProductRepository(),
+			inventory:   inventory,
+			idempotency: repository.NewInMemoryIdempotencyRepository(),
+			outbox:      outbox,
+		}
+	case "sqlite":
+		db := openOrderDB()
+		// Миграции защищены блокировкой, поэтому реплики могут запускать их одновременно
+		if os.Getenv("AUTO_MIGRATE") != "false" {
+			applied, err := newMigrator(db).Up(context.Background())
+			if err != nil {
+				log.Fatalf("Failed to migrate order database: %v", err)
+			}
+			for _, migration := range applied {
+				log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+			}
+		}
+		return repositories{
+			orders:      repository.NewSQLOrderRepository(db),
+			products:    repository.NewSQLProductRepository(db),
+			inventory:   repository.NewSQLInventoryRepository(db),
+			idempotency: repository.NewSQLIdempotencyRepository(db),
+			outbox:      repository.NewSQLOutboxRepository(db),
+		}
+	default:
+		log.Fatalf("Unknown ORDER_STORE %q, expected sqlite or memory", store)
+		return repositories{}
+	}
+}
+
+// newEventPublisher выбирает публикацию событий по EVENT_PUBLISHER: log (по умолчанию) пишет
+// события в лог, nats — в NATS JetStream
+func newEventPublisher() events.EventPublisher {
+	switch publisher := os.Getenv("EVENT_PUBLISHER"); publisher {
+	case "", "log":
+		return events.NewInMemoryEventPublisher()
+	case "nats":
+		config := events.NATSConfig{
+			URL:           os.Getenv("NATS_URL"),
+			Stream:        os.Getenv("NATS_STREAM"),
+			SubjectPrefix: os.Getenv("NATS_SUBJECT_PREFIX"),
+			AckTimeout:    getEnvDuration("NATS_ACK_TIMEOUT", 5*time.Second),
+		}
+		if config.URL == "" {
+			config.URL = "nats://localhost:4222"
+		}
+		if config.Stream == "" {
+			config.Stream = "ORDER_EVENTS"
+		}
+		if config.SubjectPrefix == "" {
+			config.SubjectPrefix = "orders"
+		}
+		natsPublisher, err := events.NewNATSEventPublisher(config)
+		if err != nil {
+			log.Fatalf("Failed to connect to NATS: %v", err)
+		}
+		log.Printf("Publishing order events to NATS stream %s (%s.>)", config.Stream, config.SubjectPrefix)
+		return natsPublisher
+	default:
+		log.Fatalf("Unknown EVENT_PUBLISHER %q, expected log or nats", publisher)
+		return nil
+	}
+}
+
+func orderStore() string {
+	if store := os.Getenv("ORDER_STORE"); store != "" {
+		return store
+	}
+	return "sqlite"
+}
+
+func openOrderDB() *sql.DB {
+	if store := orderStore(); store != "sqlite" {
+		log.Fatalf("ORDER_STORE %q has no database", store)
+	}
+	dsn := os.Getenv("ORDER_DB_DSN")
+	if dsn == "" {
+		// _txlock=immediate: транзакция сразу берёт блокировку записи, иначе параллельные
+		// транзакции, начавшиеся с чтения, получают SQLITE_BUSY при переходе к записи
+		dsn = "file:orders.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		log.Fatalf("Failed to open order database: %v", err)
+	}
+	return db
+}
+
+func newMigrator(db *sql.DB) *migrate.Migrator {
+	migrator, err := migrate.New(db, migrate.SQLite, migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	return migrator
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
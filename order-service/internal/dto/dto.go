@@ -1,15 +1,73 @@
 package dto
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"errors"
+	"order-service/models"
+	"strconv"
+	"strings"
 
+	"github.com/google/uuid"
+)
+
+// OrderItemRequest — товар из каталога; название и цену заказ берёт из каталога
 type OrderItemRequest struct {
-	ProductName string  `json:"productName"`
-	Quantity    int     `json:"quantity"`
-	Price       float64 `json:"price"`
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
 }
 
 type CreateOrderRequest struct {
 	Items []OrderItemRequest `json:"items"`
+	// Необязательно; если указана, должна совпадать с валютой цен товаров
+	Currency string `json:"currency,omitempty"`
+}
+
+type CreateProductRequest struct {
+	SKU   string `json:"sku"`
+	Name  string `json:"name"`
+	Price Amount `json:"price"`
+	// ISO 4217, по умолчанию models.DefaultCurrency
+	Currency string `json:"currency,omitempty"`
+	// По умолчанию товар сразу доступен для заказа
+	Active *bool `json:"active,omitempty"`
+}
+
+// UpdateProductRequest — меняются только переданные поля
+type UpdateProductRequest struct {
+	Name     *string `json:"name,omitempty"`
+	Price    *Amount `json:"price,omitempty"`
+	Currency *string `json:"currency,omitempty"`
+	Active   *bool   `json:"active,omitempty"`
+}
+
+// Amount — десятичная сумма из запроса: строкой ("10.50") или, как раньше, числом (10.5).
+// Хранится текстом, чтобы не терять точность до разбора в models.Money.
+type Amount string
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*a = Amount(text)
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return errors.New("amount must be a decimal string or a number")
+	}
+	*a = Amount(number.String())
+	// Экспоненциальная запись (1e3) приводится к обычной
+	if strings.ContainsAny(string(number), "eE") {
+		value, err := number.Float64()
+		if err != nil {
+			return errors.New("amount must be a decimal string or a number")
+		}
+		*a = Amount(strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	return nil
+}
+
+type SetStockRequest struct {
+	OnHand *int `json:"onHand"`
 }
 
 type UpdateOrderStatusRequest struct {
@@ -23,8 +81,29 @@ type Response struct {
 }
 
 type ErrorDTO struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+type OrderTransitionsResponse struct {
+	OrderID            uuid.UUID            `json:"orderId"`
+	Status             models.OrderStatus   `json:"status"`
+	AllowedTransitions []models.OrderStatus `json:"allowedTransitions"`
+}
+
+type InvalidTransitionDetails struct {
+	CurrentStatus      models.OrderStatus   `json:"currentStatus"`
+	RequestedStatus    models.OrderStatus   `json:"requestedStatus"`
+	AllowedTransitions []models.OrderStatus `json:"allowedTransitions"`
+}
+
+type UnavailableProductsDetails struct {
+	SKUs []string `json:"skus"`
+}
+
+type OutOfStockDetails struct {
+	Items []models.OutOfStockItem `json:"items"`
 }
 
 type PaginatedResponse struct {
@@ -33,11 +112,15 @@ type PaginatedResponse struct {
 	Meta    MetaDTO     `json:"meta"`
 }
 
+// MetaDTO — page/totalPages/totalItems в режиме page/limit, nextCursor/prevCursor — в режиме курсоров.
+// totalPages и totalItems отдаются всегда, как до появления курсоров: в режиме курсоров они нулевые.
 type MetaDTO struct {
-	Page       int `json:"page"`
-	Limit      int `json:"limit"`
-	TotalPages int `json:"totalPages"`
-	TotalItems int `json:"totalItems"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	TotalPages int    `json:"totalPages"`
+	TotalItems int    `json:"totalItems"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
 }
 
 type UserExistsRequest struct {
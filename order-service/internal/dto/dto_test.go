@@ -0,0 +1,67 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"order-service/models"
+	"testing"
+)
+
+// Цена из запроса — строкой или числом, в том числе в экспоненциальной записи — доходит
+// до models.ParseMoney без потери точности
+func TestAmountToMoney(t *testing.T) {
+	tests := []struct {
+		price    string
+		currency string
+		want     int64
+		wantErr  error
+	}{
+		{`"10.50"`, "USD", 1050, nil},
+		{`10.5`, "USD", 1050, nil},
+		{`"0.125"`, "USD", 13, nil},
+		{`0.125`, "USD", 13, nil},
+		{`-0.125`, "USD", -13, nil},
+		{`1e3`, "USD", 100000, nil},
+		{`1E3`, "JPY", 1000, nil},
+		{`1.5e2`, "USD", 15000, nil},
+		{`1.25e-1`, "USD", 13, nil},
+		{`1.2345e0`, "KWD", 1235, nil},
+		// Большие числа без экспоненты не проходят через float64
+		{`92233720368547758.07`, "USD", 9223372036854775807, nil},
+		// Строка не приводится: экспонента в ней — ошибка разбора суммы
+		{`"1e3"`, "USD", 0, models.ErrInvalidAmount},
+		{`"abc"`, "USD", 0, models.ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		var req CreateProductRequest
+		if err := json.Unmarshal([]byte(`{"sku":"W1","name":"Widget","price":`+tt.price+`}`), &req); err != nil {
+			t.Errorf("price %s: Unmarshal: %v", tt.price, err)
+			continue
+		}
+
+		got, err := models.ParseMoney(string(req.Price), tt.currency)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("price %s (%q): error = %v, want %v", tt.price, req.Price, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("price %s (%q): ParseMoney: %v", tt.price, req.Price, err)
+			continue
+		}
+		if got.Amount != tt.want {
+			t.Errorf("price %s (%q) = %d, want %d", tt.price, req.Price, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestAmountRejectsNonNumbers(t *testing.T) {
+	for _, price := range []string{`true`, `{}`, `[1]`, `1e400`} {
+		var amount Amount
+		if err := json.Unmarshal([]byte(price), &amount); err == nil {
+			t.Errorf("price %s accepted as %q", price, amount)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Последняя часть темы по типу события: orders.created, orders.status_updated, ...
+var eventSubjects = map[EventType]string{
+	OrderCreated:       "created",
+	OrderStatusUpdated: "status_updated",
+	OrderCancelled:     "cancelled",
+}
+
+// NATSConfig — подключение к NATS и поток JetStream для событий заказов
+type NATSConfig struct {
+	URL    string
+	Stream string
+	// Префикс тем; поток принимает все темы <prefix>.>
+	SubjectPrefix string
+	// Сколько ждать подтверждения от JetStream
+	AckTimeout time.Duration
+}
+
+// NATSEventPublisher публикует события в NATS JetStream. Nats-Msg-Id — id события, поэтому
+// повтор публикации (например, relay outbox после сбоя) JetStream отбрасывает как дубликат
+// в пределах окна дедупликации потока. Publish возвращается после подтверждения записи.
+type NATSEventPublisher struct {
+	config NATSConfig
+	conn   *nats.Conn
+	js     jetstream.JetStream
+
+	mu sync.Mutex
+	// Поток создаётся при первой публикации: при старте сервиса NATS может быть ещё недоступен
+	streamReady bool
+}
+
+func NewNATSEventPublisher(config NATSConfig) (*NATSEventPublisher, error) {
+	conn, err := nats.Connect(config.URL,
+		nats.Name("order-service"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Disconnected from NATS: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			log.Printf("Reconnected to NATS at %s", conn.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &NATSEventPublisher{config: config, conn: conn, js: js}, nil
+}
+
+func (p *NATSEventPublisher) Publish(event *OrderEvent) error {
+	ack, err := p.publish(event)
+	if err != nil {
+		return err
+	}
+	if ack.Duplicate {
+		log.Printf("Event %s was already published to NATS stream %s", event.ID, ack.Stream)
+	}
+	return nil
+}
+
+// publish отправляет событие и возвращает подтверждение JetStream
+func (p *NATSEventPublisher) publish(event *OrderEvent) (*jetstream.PubAck, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.AckTimeout)
+	defer cancel()
+
+	if err := p.ensureStream(ctx); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	msg := nats.NewMsg(p.Subject(event.Type))
+	msg.Data = data
+	msg.Header.Set("Content-Type", "application/json")
+
+	ack, err := p.js.PublishMsg(ctx, msg, jetstream.WithMsgID(event.ID.String()))
+	if err != nil {
+		return nil, fmt.Errorf("publish %s to NATS: %w", event.ID, err)
+	}
+	return ack, nil
+}
+
+// Subject — тема NATS для типа события
+func (p *NATSEventPublisher) Subject(eventType EventType) string {
+	name, ok := eventSubjects[eventType]
+	if !ok {
+		name = strings.ToLower(string(eventType))
+	}
+	return p.config.SubjectPrefix + "." + name
+}
+
+func (p *NATSEventPublisher) ensureStream(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.streamReady {
+		return nil
+	}
+	_, err := p.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     p.config.Stream,
+		Subjects: []string{p.config.SubjectPrefix + ".>"},
+		Storage:  jetstream.FileStorage,
+	})
+	if err != nil {
+		return fmt.Errorf("create NATS stream %s: %w", p.config.Stream, err)
+	}
+	p.streamReady = true
+	return nil
+}
+
+// Close дожидается отправки буфера и закрывает соединение
+func (p *NATSEventPublisher) Close() error {
+	return p.conn.Drain()
+}
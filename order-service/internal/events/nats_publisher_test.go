@@ -0,0 +1,178 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	testStream        = "TEST_ORDERS"
+	testSubjectPrefix = "orders"
+)
+
+// runJetStream запускает встроенный NATS с JetStream на случайном порту
+func runJetStream(t *testing.T) *server.Server {
+	t.Helper()
+
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+func newTestPublisher(t *testing.T, srv *server.Server, subjectPrefix string, ackTimeout time.Duration) *NATSEventPublisher {
+	t.Helper()
+
+	publisher, err := NewNATSEventPublisher(NATSConfig{
+		URL:           srv.ClientURL(),
+		Stream:        testStream,
+		SubjectPrefix: subjectPrefix,
+		AckTimeout:    ackTimeout,
+	})
+	if err != nil {
+		t.Fatalf("NewNATSEventPublisher: %v", err)
+	}
+	t.Cleanup(func() { publisher.Close() })
+	return publisher
+}
+
+func newTestEvent(eventType EventType) *OrderEvent {
+	return &OrderEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		Data:      map[string]interface{}{"orderId": uuid.NewString()},
+	}
+}
+
+// Каждый тип события уходит в свою тему, Nats-Msg-Id — id события
+func TestNATSEventPublisherSubjectsAndMsgID(t *testing.T) {
+	srv := runJetStream(t)
+	publisher := newTestPublisher(t, srv, testSubjectPrefix, 5*time.Second)
+
+	tests := []struct {
+		eventType EventType
+		subject   string
+	}{
+		{OrderCreated, "orders.created"},
+		{OrderStatusUpdated, "orders.status_updated"},
+		{OrderCancelled, "orders.cancelled"},
+		// Тип без явной темы — имя типа в нижнем регистре
+		{EventType("ORDER_ARCHIVED"), "orders.order_archived"},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(string(tt.eventType), func(t *testing.T) {
+			if got := publisher.Subject(tt.eventType); got != tt.subject {
+				t.Errorf("Subject = %q, want %q", got, tt.subject)
+			}
+
+			event := newTestEvent(tt.eventType)
+			if err := publisher.Publish(event); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+
+			stream, err := publisher.js.Stream(ctx, testStream)
+			if err != nil {
+				t.Fatalf("Stream: %v", err)
+			}
+			msg, err := stream.GetLastMsgForSubject(ctx, tt.subject)
+			if err != nil {
+				t.Fatalf("no message on %s: %v", tt.subject, err)
+			}
+			if got := msg.Header.Get(jetstream.MsgIDHeader); got != event.ID.String() {
+				t.Errorf("Nats-Msg-Id = %q, want %q", got, event.ID)
+			}
+		})
+	}
+}
+
+// Повтор публикации с тем же id JetStream подтверждает как дубликат и не записывает второй раз
+func TestNATSEventPublisherDuplicate(t *testing.T) {
+	srv := runJetStream(t)
+	publisher := newTestPublisher(t, srv, testSubjectPrefix, 5*time.Second)
+	event := newTestEvent(OrderCreated)
+
+	first, err := publisher.publish(event)
+	if err != nil {
+		t.Fatalf("first publish: %v", err)
+	}
+	if first.Duplicate {
+		t.Fatal("first publish acknowledged as a duplicate")
+	}
+
+	second, err := publisher.publish(event)
+	if err != nil {
+		t.Fatalf("second publish: %v", err)
+	}
+	if !second.Duplicate {
+		t.Error("second publish was not acknowledged as a duplicate")
+	}
+	if second.Sequence != first.Sequence {
+		t.Errorf("duplicate sequence = %d, want %d", second.Sequence, first.Sequence)
+	}
+
+	// Publish не считает дубликат ошибкой: relay outbox может повторять события
+	if err := publisher.Publish(event); err != nil {
+		t.Errorf("Publish of a duplicate: %v", err)
+	}
+}
+
+// Если подтверждение не пришло за AckTimeout, Publish возвращает ошибку
+func TestNATSEventPublisherAckTimeout(t *testing.T) {
+	srv := runJetStream(t)
+	const ackTimeout = 200 * time.Millisecond
+	publisher := newTestPublisher(t, srv, "silent", ackTimeout)
+	// Поток не создаётся: темы silent.> слушает подписчик, который не отвечает
+	publisher.streamReady = true
+
+	conn, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(conn.Close)
+	sub, err := conn.SubscribeSync("silent.>")
+	if err != nil {
+		t.Fatalf("SubscribeSync: %v", err)
+	}
+	if err := conn.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	started := time.Now()
+	err = publisher.Publish(newTestEvent(OrderCreated))
+	if err == nil {
+		t.Fatal("Publish succeeded without an ack")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(started); elapsed < ackTimeout {
+		t.Errorf("Publish returned after %s, before AckTimeout %s", elapsed, ackTimeout)
+	}
+
+	if _, err := sub.NextMsg(time.Second); err != nil {
+		t.Errorf("message did not reach the subscriber: %v", err)
+	}
+}
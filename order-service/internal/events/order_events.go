@@ -49,6 +49,7 @@ func NewOrderCreatedEvent(order *models.Order) *OrderEvent {
 			"orderId":     order.ID,
 			"userId":      order.UserID,
 			"totalAmount": order.TotalAmount,
+			"currency":    order.Currency,
 			"itemsCount":  len(order.Items),
 			"status":      order.Status,
 		},
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"order-service/internal/repository"
+	"order-service/internal/service"
+	"strings"
+	"time"
+)
+
+// MetricsHandler отдаёт метрики outbox в текстовом формате Prometheus. Очередь
+// (pending, lag) общая для всех реплик, счётчики relay — только этого экземпляра.
+func MetricsHandler(outbox repository.OutboxRepository, relay *service.OutboxRelay) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := outbox.Stats()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "METRICS_FAILED", err.Error())
+			return
+		}
+
+		// Лаг — возраст самого старого неопубликованного события, 0 при пустой очереди
+		var lag time.Duration
+		if stats.OldestPendingAt != nil {
+			lag = time.Since(*stats.OldestPendingAt)
+		}
+		relayStats := relay.Stats()
+
+		var b strings.Builder
+		writeMetric(&b, "order_outbox_pending_events", "gauge", "Order events waiting in the outbox", float64(stats.Pending))
+		writeMetric(&b, "order_outbox_lag_seconds", "gauge", "Age of the oldest unpublished order event", lag.Seconds())
+		writeMetric(&b, "order_outbox_last_dispatch_delay_seconds", "gauge", "Time from write to publish of the last dispatched event", relayStats.LastDelay.Seconds())
+		writeMetric(&b, "order_outbox_dispatched_total", "counter", "Order events published by this instance", float64(relayStats.Dispatched))
+		writeMetric(&b, "order_outbox_publish_failures_total", "counter", "Failed order event publish attempts of this instance", float64(relayStats.Failures))
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(b.String()))
+	}
+}
+
+func writeMetric(b *strings.Builder, name, kind, help string, value float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+}
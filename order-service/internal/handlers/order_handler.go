@@ -2,25 +2,35 @@ package handlers
 
 import (
 	"encoding/json"
-	"math"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"order-service/internal/dto"
 	"order-service/internal/middleware"
+	"order-service/internal/repository"
 	"order-service/internal/service"
+	"order-service/models"
 	"order-service/validator"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ChrolloLucii/control-system/shared/cursor"
+	"github.com/ChrolloLucii/control-system/shared/etag"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
 type OrderHandler struct {
 	orderService service.OrderService
+	cursors      *cursor.Codec
 }
 
-func NewOrderHandler(orderService service.OrderService) *OrderHandler {
+func NewOrderHandler(orderService service.OrderService, cursors *cursor.Codec) *OrderHandler {
 	return &OrderHandler{
 		orderService: orderService,
+		cursors:      cursors,
 	}
 }
 
@@ -48,12 +58,40 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	order, err := h.orderService.CreateOrder(claims.UserID, &req, token)
+	var unavailableErr *service.UnavailableProductsError
+	if errors.As(err, &unavailableErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(dto.Response{
+			Success: false,
+			Error: &dto.ErrorDTO{
+				Code:    "PRODUCT_UNAVAILABLE",
+				Message: unavailableErr.Error(),
+				Details: dto.UnavailableProductsDetails{SKUs: unavailableErr.SKUs},
+			},
+		})
+		return
+	}
+	var stockErr *models.OutOfStockError
+	if errors.As(err, &stockErr) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(dto.Response{
+			Success: false,
+			Error: &dto.ErrorDTO{
+				Code:    "OUT_OF_STOCK",
+				Message: stockErr.Error(),
+				Details: dto.OutOfStockDetails{Items: stockErr.Items},
+			},
+		})
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "CREATE_FAILED", err.Error())
 		return
 	}
 
-	respondWithSuccess(w, http.StatusCreated, order)
+	respondWithOrder(w, http.StatusCreated, order)
 }
 
 func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
@@ -78,7 +116,7 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithSuccess(w, http.StatusOK, order)
+	respondWithOrder(w, http.StatusOK, order)
 }
 
 func (h *OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
@@ -88,43 +126,110 @@ func (h *OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
+	query := r.URL.Query()
 
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
 	if limit < 1 {
 		limit = 10
 	}
 
-	sortBy := r.URL.Query().Get("sort")
+	sortBy := query.Get("sort")
 	if sortBy == "" {
 		sortBy = "createdAt_desc"
 	}
 
-	orders, total, err := h.orderService.GetUserOrders(claims.UserID, page, limit, sortBy)
+	// Устаревший режим page/limit
+	if isLegacyPagination(query) {
+		page, _ := strconv.Atoi(query.Get("page"))
+		if page < 1 {
+			page = 1
+		}
+
+		orders, total, err := h.orderService.GetUserOrders(claims.UserID, page, limit, sortBy)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
+			return
+		}
+
+		respondWithPage(w, orders, page, limit, total)
+		return
+	}
+
+	if err := validator.ValidateOrderSort(sortBy); err != nil {
+		respondWithError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+	query.Set("sort", sortBy)
+
+	seek, err := h.decodeCursor(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_CURSOR", err.Error())
+		return
+	}
+
+	orders, hasMore, err := h.orderService.GetUserOrdersPage(claims.UserID, sortBy, seek, limit)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
 		return
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	h.respondWithCursorPage(w, r, query, orders, seek, hasMore, limit)
+}
 
-	response := dto.PaginatedResponse{
-		Success: true,
-		Data:    orders,
-		Meta: dto.MetaDTO{
-			Page:       page,
-			Limit:      limit,
-			TotalPages: totalPages,
-			TotalItems: total,
-		},
+// AdminListOrders — заказы всех пользователей с фильтрами, только для администратора
+func (h *OrderHandler) AdminListOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = 10
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = "createdAt_desc"
+	}
+	if err := validator.ValidateOrderSort(sortBy); err != nil {
+		respondWithError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	filter, err := parseOrderFilter(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	if isLegacyPagination(query) {
+		page, _ := strconv.Atoi(query.Get("page"))
+		if page < 1 {
+			page = 1
+		}
+
+		orders, total, err := h.orderService.ListOrders(filter, page, limit, sortBy)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
+			return
+		}
+
+		respondWithPage(w, orders, page, limit, total)
+		return
+	}
+
+	query.Set("sort", sortBy)
+	seek, err := h.decodeCursor(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_CURSOR", err.Error())
+		return
+	}
+
+	orders, hasMore, err := h.orderService.ListOrdersPage(filter, sortBy, seek, limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
+		return
+	}
+
+	h.respondWithCursorPage(w, r, query, orders, seek, hasMore, limit)
 }
 
 func (h *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
@@ -154,13 +259,16 @@ func (h *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request)
 
 	isAdmin := hasRole(claims.Roles, "admin")
 
-	order, err := h.orderService.UpdateOrderStatus(orderID, claims.UserID, req.Status, isAdmin)
+	order, err := h.orderService.UpdateOrderStatus(orderID, claims.UserID, req.Status, isAdmin, etag.ParseIfMatch(r.Header.Get("If-Match")))
+	if respondWithTransitionError(w, err) || respondWithVersionConflict(w, err) {
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "UPDATE_FAILED", err.Error())
 		return
 	}
 
-	respondWithSuccess(w, http.StatusOK, order)
+	respondWithOrder(w, http.StatusOK, order)
 }
 
 func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
@@ -179,25 +287,145 @@ func (h *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
 
 	isAdmin := hasRole(claims.Roles, "admin")
 
-	order, err := h.orderService.CancelOrder(orderID, claims.UserID, isAdmin)
+	order, err := h.orderService.CancelOrder(orderID, claims.UserID, isAdmin, etag.ParseIfMatch(r.Header.Get("If-Match")))
+	if respondWithTransitionError(w, err) || respondWithVersionConflict(w, err) {
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "CANCEL_FAILED", err.Error())
 		return
 	}
 
-	respondWithSuccess(w, http.StatusOK, order)
+	respondWithOrder(w, http.StatusOK, order)
+}
+
+func (h *OrderHandler) GetOrderTransitions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserContextKey).(*middleware.Claims)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "user not authenticated")
+		return
+	}
+
+	orderID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_ID", "invalid order ID")
+		return
+	}
+
+	order, allowed, err := h.orderService.GetOrderTransitions(orderID, claims.UserID, hasRole(claims.Roles, "admin"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "ORDER_NOT_FOUND", err.Error())
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, dto.OrderTransitionsResponse{
+		OrderID:            order.ID,
+		Status:             order.Status,
+		AllowedTransitions: allowed,
+	})
 }
 
-func (h *OrderHandler) RegisterRoutes(r chi.Router, jwtSecret string) {
+// RegisterRoutes — idempotency обрабатывает Idempotency-Key на изменяющих заказ запросах
+func (h *OrderHandler) RegisterRoutes(r chi.Router, auth, idempotency func(http.Handler) http.Handler) {
 	r.Route("/api/v1/orders", func(r chi.Router) {
-		r.Use(middleware.AuthMiddleware(jwtSecret))
+		r.Use(auth)
 
-		r.Post("/", h.CreateOrder)
+		r.With(idempotency).Post("/", h.CreateOrder)
 		r.Get("/", h.GetUserOrders)
 		r.Get("/{id}", h.GetOrder)
-		r.Put("/{id}/status", h.UpdateOrderStatus)
-		r.Delete("/{id}", h.CancelOrder)
+		r.With(idempotency).Put("/{id}/status", h.UpdateOrderStatus)
+		r.Get("/{id}/transitions", h.GetOrderTransitions)
+		r.With(idempotency).Delete("/{id}", h.CancelOrder)
 	})
+
+	r.Route("/api/v1/admin/orders", func(r chi.Router) {
+		r.Use(auth)
+		r.Use(middleware.AdminMiddleware)
+
+		r.Get("/", h.AdminListOrders)
+	})
+}
+
+// parseOrderFilter читает фильтры: userId, status (через запятую или повтором),
+// createdFrom/createdTo (RFC 3339), currency, minAmount/maxAmount (в валюте currency), product
+func parseOrderFilter(query url.Values) (repository.OrderFilter, error) {
+	var filter repository.OrderFilter
+
+	if value := query.Get("userId"); value != "" {
+		userID, err := uuid.Parse(value)
+		if err != nil {
+			return filter, errors.New("invalid userId")
+		}
+		filter.UserID = &userID
+	}
+
+	for _, value := range query["status"] {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.TrimSpace(status)
+			if err := validator.ValidateOrderStatus(status); err != nil {
+				return filter, fmt.Errorf("invalid status %q", status)
+			}
+			filter.Statuses = append(filter.Statuses, models.OrderStatus(status))
+		}
+	}
+
+	var err error
+	if filter.CreatedFrom, err = parseTimeParam(query, "createdFrom"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedTo, err = parseTimeParam(query, "createdTo"); err != nil {
+		return filter, err
+	}
+	filter.Currency = query.Get("currency")
+	if filter.Currency == "" && (query.Get("minAmount") != "" || query.Get("maxAmount") != "") {
+		// Суммы разных валют несравнимы: границы относятся к одной валюте
+		filter.Currency = models.DefaultCurrency
+	}
+	if filter.Currency != "" {
+		if _, err := models.CurrencyExponent(filter.Currency); err != nil {
+			return filter, fmt.Errorf("invalid currency %q", filter.Currency)
+		}
+	}
+	if filter.MinAmount, err = parseAmountParam(query, "minAmount", filter.Currency); err != nil {
+		return filter, err
+	}
+	if filter.MaxAmount, err = parseAmountParam(query, "maxAmount", filter.Currency); err != nil {
+		return filter, err
+	}
+	filter.ProductName = strings.TrimSpace(query.Get("product"))
+
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && filter.CreatedFrom.After(*filter.CreatedTo) {
+		return filter, errors.New("createdFrom must not be after createdTo")
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return filter, errors.New("minAmount must not be greater than maxAmount")
+	}
+	return filter, nil
+}
+
+func parseTimeParam(query url.Values, name string) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, expected RFC 3339 time", name)
+	}
+	return &parsed, nil
+}
+
+// parseAmountParam возвращает сумму в минимальных единицах валюты
+func parseAmountParam(query url.Values, name, currency string) (*int64, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := models.ParseMoney(value, currency)
+	if err != nil || parsed.Amount < 0 {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return &parsed.Amount, nil
 }
 
 func hasRole(roles []string, role string) bool {
@@ -221,6 +449,47 @@ func respondWithError(w http.ResponseWriter, status int, code, message string) {
 	})
 }
 
+// respondWithTransitionError отвечает 409 со списком разрешённых статусов,
+// если err — недопустимый переход статуса
+func respondWithTransitionError(w http.ResponseWriter, err error) bool {
+	var transitionErr *models.InvalidTransitionError
+	if !errors.As(err, &transitionErr) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(dto.Response{
+		Success: false,
+		Error: &dto.ErrorDTO{
+			Code:    "INVALID_TRANSITION",
+			Message: transitionErr.Error(),
+			Details: dto.InvalidTransitionDetails{
+				CurrentStatus:      transitionErr.From,
+				RequestedStatus:    transitionErr.To,
+				AllowedTransitions: transitionErr.Allowed,
+			},
+		},
+	})
+	return true
+}
+
+// respondWithVersionConflict отвечает 412, если заказ не той версии, что в If-Match,
+// или его изменили параллельно
+func respondWithVersionConflict(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, repository.ErrOrderVersionConflict) {
+		return false
+	}
+	respondWithError(w, http.StatusPreconditionFailed, "PRECONDITION_FAILED", err.Error())
+	return true
+}
+
+// respondWithOrder отдаёт заказ с ETag его версии — для If-Match в следующем изменении
+func respondWithOrder(w http.ResponseWriter, status int, order *models.Order) {
+	w.Header().Set("ETag", etag.Format(order.Version))
+	respondWithSuccess(w, status, order)
+}
+
 func respondWithSuccess(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"order-service/internal/dto"
+	"order-service/internal/repository"
+	"order-service/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChrolloLucii/control-system/shared/cursor"
+	"github.com/google/uuid"
+)
+
+// Параметры пагинации не входят в отпечаток выборки
+var paginationParams = []string{"cursor", "limit", "page"}
+
+// orderCursor — содержимое курсора: позиция в выборке и отпечаток её параметров
+type orderCursor struct {
+	Query       string    `json:"q"`
+	CreatedAt   time.Time `json:"c"`
+	TotalAmount int64     `json:"a"`
+	ID          uuid.UUID `json:"i"`
+	Backward    bool      `json:"b,omitempty"`
+}
+
+// isLegacyPagination — клиент не перешёл на курсоры: без параметра cursor список отдаётся
+// постранично, как раньше. Первая страница в режиме курсоров — ?cursor= с пустым значением.
+func isLegacyPagination(query url.Values) bool {
+	return !query.Has("cursor")
+}
+
+func (h *OrderHandler) decodeCursor(query url.Values) (*repository.OrderSeek, error) {
+	value := query.Get("cursor")
+	if value == "" {
+		return nil, nil
+	}
+
+	var c orderCursor
+	if err := h.cursors.Decode(value, &c); err != nil {
+		return nil, err
+	}
+	if c.Query != cursor.Fingerprint(query, paginationParams...) {
+		return nil, errors.New("cursor does not match the query parameters")
+	}
+	return &repository.OrderSeek{CreatedAt: c.CreatedAt, TotalAmount: c.TotalAmount, ID: c.ID, Backward: c.Backward}, nil
+}
+
+func (h *OrderHandler) encodeCursor(query url.Values, seek *repository.OrderSeek) (string, error) {
+	return h.cursors.Encode(orderCursor{
+		Query:       cursor.Fingerprint(query, paginationParams...),
+		CreatedAt:   seek.CreatedAt.UTC(),
+		TotalAmount: seek.TotalAmount,
+		ID:          seek.ID,
+		Backward:    seek.Backward,
+	})
+}
+
+// respondWithCursorPage отдаёт страницу с курсорами соседних страниц в meta и в заголовке Link
+func (h *OrderHandler) respondWithCursorPage(w http.ResponseWriter, r *http.Request, query url.Values, orders []*models.Order, seek *repository.OrderSeek, hasMore bool, limit int) {
+	meta := dto.MetaDTO{Limit: limit}
+
+	if len(orders) > 0 {
+		backward := seek != nil && seek.Backward
+		var links []string
+		// Вперёд есть страницы, если они остались после этой или мы пришли с них назад
+		if hasMore && !backward || backward {
+			next, err := h.encodeCursor(query, repository.SeekFor(orders[len(orders)-1], false))
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
+				return
+			}
+			meta.NextCursor = next
+			links = append(links, pageLink(r, query, next, limit, "next"))
+		}
+		if hasMore && backward || seek != nil && !backward {
+			prev, err := h.encodeCursor(query, repository.SeekFor(orders[0], true))
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
+				return
+			}
+			meta.PrevCursor = prev
+			links = append(links, pageLink(r, query, prev, limit, "prev"))
+		}
+		if len(links) > 0 {
+			w.Header().Set("Link", strings.Join(links, ", "))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.PaginatedResponse{
+		Success: true,
+		Data:    orders,
+		Meta:    meta,
+	})
+}
+
+func pageLink(r *http.Request, query url.Values, cursorValue string, limit int, rel string) string {
+	params := url.Values{}
+	for key, values := range query {
+		params[key] = values
+	}
+	params.Del("page")
+	params.Set("cursor", cursorValue)
+	params.Set("limit", strconv.Itoa(limit))
+	return "<" + r.URL.Path + "?" + params.Encode() + `>; rel="` + rel + `"`
+}
+
+func respondWithPage(w http.ResponseWriter, data interface{}, page, limit, total int) {
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+
+	response := dto.PaginatedResponse{
+		Success: true,
+		Data:    data,
+		Meta: dto.MetaDTO{
+			Page:       page,
+			Limit:      limit,
+			TotalPages: totalPages,
+			TotalItems: total,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
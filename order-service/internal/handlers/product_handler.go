@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"order-service/internal/dto"
+	"order-service/internal/middleware"
+	"order-service/internal/repository"
+	"order-service/internal/service"
+	"order-service/validator"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ProductHandler struct {
+	productService service.ProductService
+}
+
+func NewProductHandler(productService service.ProductService) *ProductHandler {
+	return &ProductHandler{productService: productService}
+}
+
+// ListProducts — активные товары каталога
+func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
+	h.listProducts(w, r, false)
+}
+
+// AdminListProducts — все товары, включая скрытые
+func (h *ProductHandler) AdminListProducts(w http.ResponseWriter, r *http.Request) {
+	h.listProducts(w, r, true)
+}
+
+func (h *ProductHandler) listProducts(w http.ResponseWriter, r *http.Request, includeInactive bool) {
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = 20
+	}
+
+	products, total, err := h.productService.ListProducts(includeInactive, page, limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
+		return
+	}
+
+	respondWithPage(w, products, page, limit, total)
+}
+
+func (h *ProductHandler) GetProduct(w http.ResponseWriter, r *http.Request) {
+	h.getProduct(w, r, false)
+}
+
+func (h *ProductHandler) AdminGetProduct(w http.ResponseWriter, r *http.Request) {
+	h.getProduct(w, r, true)
+}
+
+func (h *ProductHandler) getProduct(w http.ResponseWriter, r *http.Request, includeInactive bool) {
+	product, err := h.productService.GetProduct(chi.URLParam(r, "sku"), includeInactive)
+	if err != nil {
+		respondWithProductError(w, err, "FETCH_FAILED")
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, product)
+}
+
+func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateProductRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "invalid request body")
+		return
+	}
+
+	if err := validator.ValidateCreateProductRequest(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	product, err := h.productService.CreateProduct(&req)
+	if err != nil {
+		respondWithProductError(w, err, "CREATE_FAILED")
+		return
+	}
+
+	respondWithSuccess(w, http.StatusCreated, product)
+}
+
+func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	var req dto.UpdateProductRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "invalid request body")
+		return
+	}
+
+	if err := validator.ValidateUpdateProductRequest(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	product, err := h.productService.UpdateProduct(chi.URLParam(r, "sku"), &req)
+	if err != nil {
+		respondWithProductError(w, err, "UPDATE_FAILED")
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, product)
+}
+
+func (h *ProductHandler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	if err := h.productService.DeleteProduct(chi.URLParam(r, "sku")); err != nil {
+		respondWithProductError(w, err, "DELETE_FAILED")
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, map[string]string{"message": "product deleted"})
+}
+
+func (h *ProductHandler) GetStock(w http.ResponseWriter, r *http.Request) {
+	level, err := h.productService.GetStock(chi.URLParam(r, "sku"))
+	if err != nil {
+		respondWithProductError(w, err, "FETCH_FAILED")
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, level)
+}
+
+func (h *ProductHandler) SetStock(w http.ResponseWriter, r *http.Request) {
+	var req dto.SetStockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "invalid request body")
+		return
+	}
+
+	if err := validator.ValidateSetStockRequest(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	level, err := h.productService.SetStock(chi.URLParam(r, "sku"), *req.OnHand)
+	if err != nil {
+		respondWithProductError(w, err, "UPDATE_FAILED")
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, level)
+}
+
+// RegisterRoutes — каталог открыт всем, изменять его может только администратор
+func (h *ProductHandler) RegisterRoutes(r chi.Router, auth func(http.Handler) http.Handler) {
+	r.Route("/api/v1/products", func(r chi.Router) {
+		r.Get("/", h.ListProducts)
+		r.Get("/{sku}", h.GetProduct)
+	})
+
+	r.Route("/api/v1/admin/products", func(r chi.Router) {
+		r.Use(auth)
+		r.Use(middleware.AdminMiddleware)
+
+		r.Get("/", h.AdminListProducts)
+		r.Post("/", h.CreateProduct)
+		r.Get("/{sku}", h.AdminGetProduct)
+		r.Put("/{sku}", h.UpdateProduct)
+		r.Delete("/{sku}", h.DeleteProduct)
+		r.Get("/{sku}/stock", h.GetStock)
+		r.Put("/{sku}/stock", h.SetStock)
+	})
+}
+
+// respondWithProductError — 404 и 409 для известных ошибок каталога и склада, иначе 400 с кодом fallback
+func respondWithProductError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repository.ErrProductNotFound):
+		respondWithError(w, http.StatusNotFound, "PRODUCT_NOT_FOUND", err.Error())
+	case errors.Is(err, repository.ErrProductExists):
+		respondWithError(w, http.StatusConflict, "PRODUCT_EXISTS", err.Error())
+	case errors.Is(err, repository.ErrStockBelowReserved):
+		respondWithError(w, http.StatusConflict, "STOCK_BELOW_RESERVED", err.Error())
+	default:
+		respondWithError(w, http.StatusBadRequest, fallback, err.Error())
+	}
+}
@@ -6,7 +6,11 @@ import (
 	"net/http"
 	"order-service/internal/dto"
 	"strings"
+	"time"
 
+	"github.com/ChrolloLucii/control-system/shared/identity"
+	"github.com/ChrolloLucii/control-system/shared/jwks"
+	"github.com/ChrolloLucii/control-system/shared/revocation"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
@@ -22,9 +26,23 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
+// AuthMiddleware определяет пользователя. Запросы через шлюз несут подписанное шлюзом подтверждение
+// (X-Internal-Identity): токен пользователя шлюз уже проверил, в том числе по списку отзыва.
+// Без подтверждения проверяется сам токен — по публичным ключам user-service (JWKS) и списку отзыва.
+func AuthMiddleware(keys *jwks.Client, revocations *revocation.Cache, gatewayKeys *jwks.Client) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if assertion := r.Header.Get(identity.Header); assertion != "" {
+				claims := &Claims{}
+				if err := identity.Parse(assertion, gatewayKeys, claims); err != nil {
+					respondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "invalid internal identity")
+					return
+				}
+				ctx := context.WithValue(r.Context(), UserContextKey, claims)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
 				respondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authorization header required")
@@ -40,15 +58,22 @@ func AuthMiddleware(jwtSecret string) func(http.Handler) http.Handler {
 			tokenString := parts[1]
 			claims := &Claims{}
 
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				return []byte(jwtSecret), nil
-			})
+			token, err := jwt.ParseWithClaims(tokenString, claims, keys.KeyfuncContext(r.Context()), jwt.WithValidMethods(jwks.ValidMethods))
 
 			if err != nil || !token.Valid {
 				respondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "invalid or expired token")
 				return
 			}
 
+			var issuedAt time.Time
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			if revocations.IsRevoked(claims.ID, claims.UserID.String(), issuedAt) {
+				respondWithError(w, http.StatusUnauthorized, "TOKEN_REVOKED", "token has been revoked")
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), UserContextKey, claims)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
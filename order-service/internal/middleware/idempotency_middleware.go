@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"order-service/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// Ставится на ответ, повторённый из сохранённого
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodySize   = 1 << 20
+	// Запрос, который обрабатывается дольше, считается прерванным (например, сервис
+	// перезапустился), и ключ можно использовать снова
+	idempotencyLockTimeout = time.Minute
+)
+
+// Заголовки ответа, которые повторяются вместе с телом
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// IdempotencyMiddleware выполняет запрос с заголовком Idempotency-Key один раз, а на повторы
+// с тем же ключом отдаёт сохранённый ответ. Ключ действует в пределах пользователя и ttl;
+// тот же ключ с другим запросом (метод, путь, тело) — 422. Ответы 5xx не сохраняются,
+// такой запрос можно повторить с тем же ключом. Ставится после AuthMiddleware.
+func IdempotencyMiddleware(repo repository.IdempotencyRepository, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				respondWithError(w, http.StatusBadRequest, "INVALID_IDEMPOTENCY_KEY", "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			claims, ok := r.Context().Value(UserContextKey).(*Claims)
+			if !ok {
+				respondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "user not authenticated")
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+			if err != nil || len(body) > maxIdempotentBodySize {
+				respondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "request body is too large or unreadable")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			record := &repository.IdempotencyRecord{
+				UserID:      claims.UserID,
+				Key:         key,
+				Owner:       uuid.NewString(),
+				Fingerprint: requestFingerprint(r, body),
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(ttl),
+			}
+
+			existing, err := repo.Start(record)
+			if err == nil && existing != nil && isStale(existing, now) {
+				// Прерванную или истёкшую запись занимает только один из повторов; остальные
+				// получают ответ по записи, которую он создал
+				var replaced bool
+				if replaced, err = repo.Replace(existing, record); err == nil {
+					if replaced {
+						existing = nil
+					} else {
+						existing, err = repo.Start(record)
+					}
+				}
+			}
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "IDEMPOTENCY_FAILED", err.Error())
+				return
+			}
+
+			if existing != nil {
+				switch {
+				case existing.Fingerprint != record.Fingerprint:
+					respondWithError(w, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED",
+						"Idempotency-Key was already used with a different request")
+				case !existing.Completed:
+					respondWithError(w, http.StatusConflict, "IDEMPOTENCY_REQUEST_IN_PROGRESS",
+						"a request with this Idempotency-Key is still being processed")
+				default:
+					replay(w, existing)
+				}
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= http.StatusInternalServerError {
+				if err := repo.Release(record); err != nil {
+					log.Printf("Failed to release Idempotency-Key %q: %v", key, err)
+				}
+				return
+			}
+
+			record.Completed = true
+			record.ResponseStatus = recorder.status
+			record.ResponseHeaders = make(map[string][]string)
+			for _, name := range replayedHeaders {
+				if values := recorder.Header().Values(name); len(values) > 0 {
+					record.ResponseHeaders[name] = values
+				}
+			}
+			record.ResponseBody = recorder.body.Bytes()
+			if err := repo.Complete(record); err != nil {
+				log.Printf("Failed to store response for Idempotency-Key %q: %v", key, err)
+			}
+		})
+	}
+}
+
+// isStale — запись истекла, но ещё не удалена, или её обработка прервалась
+func isStale(record *repository.IdempotencyRecord, now time.Time) bool {
+	return !record.ExpiresAt.After(now) ||
+		!record.Completed && now.Sub(record.CreatedAt) > idempotencyLockTimeout
+}
+
+func replay(w http.ResponseWriter, record *repository.IdempotencyRecord) {
+	for name, values := range record.ResponseHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.ResponseStatus)
+	w.Write(record.ResponseBody)
+}
+
+// requestFingerprint — хеш метода, пути и тела. JSON-тело приводится к каноническому
+// виду, поэтому порядок полей и пробелы не делают запрос другим.
+func requestFingerprint(r *http.Request, body []byte) string {
+	var parsed interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&parsed); err == nil {
+		if canonical, err := json.Marshal(parsed); err == nil {
+			body = canonical
+		}
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder передаёт ответ клиенту и запоминает его для повтора
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// RunIdempotencyCleanup с заданным интервалом удаляет записи с истёкшим сроком
+func RunIdempotencyCleanup(ctx context.Context, repo repository.IdempotencyRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := repo.DeleteExpired(now); err != nil {
+				log.Printf("Idempotency key cleanup failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrIdempotencyRecordLost — запись ключа заняла другая попытка, пока эта обрабатывалась
+var ErrIdempotencyRecordLost = errors.New("idempotency key was taken over by another request")
+
+// IdempotencyRecord — запрос с заголовком Idempotency-Key и, после обработки, его ответ
+type IdempotencyRecord struct {
+	UserID uuid.UUID
+	Key    string
+	// Случайный идентификатор попытки обработки, которой принадлежит запись
+	Owner string
+	// Хеш метода, пути и тела запроса: тот же ключ с другим запросом отклоняется
+	Fingerprint string
+	// false, пока запрос обрабатывается
+	Completed       bool
+	ResponseStatus  int
+	ResponseHeaders map[string][]string
+	ResponseBody    []byte
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+}
+
+type IdempotencyRepository interface {
+	// Start сохраняет запись, если ключа ещё нет, и возвращает nil.
+	// Если ключ уже есть, возвращает существующую запись и ничего не меняет.
+	Start(record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// Replace заменяет stale на record, только если ключ всё ещё принадлежит владельцу stale.
+	// false — запись успели заменить или удалить.
+	Replace(stale, record *IdempotencyRecord) (bool, error)
+	// Complete сохраняет ответ, если запись всё ещё принадлежит record.Owner; иначе ErrIdempotencyRecordLost
+	Complete(record *IdempotencyRecord) error
+	// Release удаляет запись, если она всё ещё принадлежит record.Owner
+	Release(record *IdempotencyRecord) error
+	DeleteExpired(now time.Time) (int, error)
+}
+
+type idempotencyKey struct {
+	userID uuid.UUID
+	key    string
+}
+
+type InMemoryIdempotencyRepository struct {
+	records map[idempotencyKey]*IdempotencyRecord
+	mu      sync.Mutex
+}
+
+func NewInMemoryIdempotencyRepository() *InMemoryIdempotencyRepository {
+	return &InMemoryIdempotencyRepository{
+		records: make(map[idempotencyKey]*IdempotencyRecord),
+	}
+}
+
+func (r *InMemoryIdempotencyRepository) Start(record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := idempotencyKey{userID: record.UserID, key: record.Key}
+	if existing, exists := r.records[id]; exists {
+		copied := *existing
+		return &copied, nil
+	}
+
+	stored := *record
+	r.records[id] = &stored
+	return nil, nil
+}
+
+func (r *InMemoryIdempotencyRepository) Replace(stale, record *IdempotencyRecord) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := idempotencyKey{userID: record.UserID, key: record.Key}
+	if existing, exists := r.records[id]; !exists || existing.Owner != stale.Owner {
+		return false, nil
+	}
+
+	stored := *record
+	r.records[id] = &stored
+	return true, nil
+}
+
+func (r *InMemoryIdempotencyRepository) Complete(record *IdempotencyRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := idempotencyKey{userID: record.UserID, key: record.Key}
+	if existing, exists := r.records[id]; !exists || existing.Owner != record.Owner {
+		return ErrIdempotencyRecordLost
+	}
+
+	stored := *record
+	r.records[id] = &stored
+	return nil
+}
+
+func (r *InMemoryIdempotencyRepository) Release(record *IdempotencyRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := idempotencyKey{userID: record.UserID, key: record.Key}
+	if existing, exists := r.records[id]; exists && existing.Owner == record.Owner {
+		delete(r.records, id)
+	}
+	return nil
+}
+
+func (r *InMemoryIdempotencyRepository) DeleteExpired(now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for id, record := range r.records {
+		if !record.ExpiresAt.After(now) {
+			delete(r.records, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"errors"
+	"order-service/models"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrStockBelowReserved = errors.New("on-hand stock cannot be less than reserved")
+
+// Settlement — что сделать с резервами заказа при сохранении его нового статуса.
+// Выполняется в OrderRepository.Update вместе с проверкой версии заказа: если заказ успели
+// изменить, резервы тоже остаются как были.
+type Settlement int
+
+const (
+	SettleNone Settlement = iota
+	// SettleConfirm снимает срок с активных резервов: заказ взят в работу
+	SettleConfirm
+	// SettleCommit списывает активные резервы с остатка
+	SettleCommit
+	// SettleRelease возвращает активные резервы в доступный остаток
+	SettleRelease
+)
+
+type InventoryRepository interface {
+	// GetStock — остаток товара; для товара без остатка — нули
+	GetStock(sku string) (*models.StockLevel, error)
+	// SetStock задаёт остаток на складе; меньше зарезервированного — ErrStockBelowReserved
+	SetStock(sku string, onHand int, now time.Time) (*models.StockLevel, error)
+	// Reserve резервирует все позиции или ни одной: при нехватке — *models.OutOfStockError
+	Reserve(reservations []*models.Reservation) error
+	FindReservations(orderID uuid.UUID) ([]*models.Reservation, error)
+	// Release возвращает активные резервы заказа в доступный остаток — для заказа, который
+	// не сохранён. Резервы сохранённых заказов меняет OrderRepository.Update. Повторный вызов ничего не меняет.
+	Release(orderID uuid.UUID, now time.Time) error
+	// FindExpired возвращает ID заказов с активными резервами, истёкшими к now
+	FindExpired(now time.Time) ([]uuid.UUID, error)
+}
+
+type InMemoryInventoryRepository struct {
+	stock        map[string]*models.StockLevel
+	reservations map[uuid.UUID][]*models.Reservation
+	mu           sync.Mutex
+}
+
+func NewInMemoryInventoryRepository() *InMemoryInventoryRepository {
+	return &InMemoryInventoryRepository{
+		stock:        make(map[string]*models.StockLevel),
+		reservations: make(map[uuid.UUID][]*models.Reservation),
+	}
+}
+
+func (r *InMemoryInventoryRepository) GetStock(sku string) (*models.StockLevel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.level(sku), nil
+}
+
+// level возвращает копию остатка, чтобы вызывающий не менял его в обход блокировки
+func (r *InMemoryInventoryRepository) level(sku string) *models.StockLevel {
+	level := models.StockLevel{SKU: sku}
+	if stored, exists := r.stock[sku]; exists {
+		level = *stored
+	}
+	level.Available = level.OnHand - level.Reserved
+	return &level
+}
+
+func (r *InMemoryInventoryRepository) SetStock(sku string, onHand int, now time.Time) (*models.StockLevel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	level, exists := r.stock[sku]
+	if !exists {
+		level = &models.StockLevel{SKU: sku}
+		r.stock[sku] = level
+	}
+	if onHand < level.Reserved {
+		return nil, ErrStockBelowReserved
+	}
+	level.OnHand = onHand
+	level.UpdatedAt = now
+	return r.level(sku), nil
+}
+
+func (r *InMemoryInventoryRepository) Reserve(reservations []*models.Reservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var shortages []models.OutOfStockItem
+	for _, reservation := range reservations {
+		if available := r.level(reservation.SKU).Available; available < reservation.Quantity {
+			shortages = append(shortages, models.OutOfStockItem{
+				SKU:       reservation.SKU,
+				Requested: reservation.Quantity,
+				Available: available,
+			})
+		}
+	}
+	if len(shortages) > 0 {
+		return &models.OutOfStockError{Items: shortages}
+	}
+
+	for _, reservation := range reservations {
+		level := r.stock[reservation.SKU]
+		level.Reserved += reservation.Quantity
+		level.UpdatedAt = reservation.CreatedAt
+		stored := *reservation
+		r.reservations[reservation.OrderID] = append(r.reservations[reservation.OrderID], &stored)
+	}
+	return nil
+}
+
+func (r *InMemoryInventoryRepository) FindReservations(orderID uuid.UUID) ([]*models.Reservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reservations := []*models.Reservation{}
+	for _, stored := range r.reservations[orderID] {
+		reservation := *stored
+		reservations = append(reservations, &reservation)
+	}
+	return reservations, nil
+}
+
+func (r *InMemoryInventoryRepository) Release(orderID uuid.UUID, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.settle(orderID, SettleRelease, now)
+	return nil
+}
+
+func (r *InMemoryInventoryRepository) FindExpired(now time.Time) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var orderIDs []uuid.UUID
+	for orderID, reservations := range r.reservations {
+		for _, reservation := range reservations {
+			if reservation.Status == models.ReservationActive && reservation.ExpiresAt != nil && !reservation.ExpiresAt.After(now) {
+				orderIDs = append(orderIDs, orderID)
+				break
+			}
+		}
+	}
+	return orderIDs, nil
+}
+
+// apply вызывается InMemoryOrderRepository вместе с сохранением заказа
+func (r *InMemoryInventoryRepository) apply(orderID uuid.UUID, settlement Settlement, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.settle(orderID, settlement, now)
+}
+
+func (r *InMemoryInventoryRepository) settle(orderID uuid.UUID, settlement Settlement, now time.Time) {
+	for _, reservation := range r.reservations[orderID] {
+		switch settlement {
+		case SettleConfirm:
+			if reservation.Status == models.ReservationActive {
+				reservation.ExpiresAt = nil
+				reservation.UpdatedAt = now
+			}
+		case SettleCommit:
+			r.finish(reservation, models.ReservationCommitted, now)
+		case SettleRelease:
+			r.finish(reservation, models.ReservationReleased, now)
+		}
+	}
+}
+
+// finish закрывает активный резерв: committed списывает товар с остатка, released возвращает в доступный
+func (r *InMemoryInventoryRepository) finish(reservation *models.Reservation, status models.ReservationStatus, now time.Time) {
+	if reservation.Status != models.ReservationActive {
+		return
+	}
+
+	level := r.stock[reservation.SKU]
+	level.Reserved -= reservation.Quantity
+	if status == models.ReservationCommitted {
+		level.OnHand -= reservation.Quantity
+	}
+	level.UpdatedAt = now
+
+	reservation.Status = status
+	reservation.ExpiresAt = nil
+	reservation.UpdatedAt = now
+}
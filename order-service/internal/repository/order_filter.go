@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"order-service/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderFilter — условия выборки заказов; пустые поля не ограничивают выборку
+type OrderFilter struct {
+	UserID   *uuid.UUID
+	Statuses []models.OrderStatus
+	// Границы включительные
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Currency    string
+	// Суммы в минимальных единицах валюты; сравнивать имеет смысл только вместе с Currency
+	MinAmount *int64
+	MaxAmount *int64
+	// Подстрока названия любой позиции заказа, без учёта регистра
+	ProductName string
+}
+
+// OrderSeek — позиция курсора: заказ, после которого (Backward — до которого)
+// продолжается выборка в порядке сортировки
+type OrderSeek struct {
+	CreatedAt   time.Time
+	TotalAmount int64
+	ID          uuid.UUID
+	Backward    bool
+}
+
+func SeekFor(order *models.Order, backward bool) *OrderSeek {
+	return &OrderSeek{CreatedAt: order.CreatedAt, TotalAmount: order.TotalAmount.Amount, ID: order.ID, Backward: backward}
+}
+
+// Matches проверяет заказ в памяти так же, как SQL-хранилище проверяет его в запросе
+func (f OrderFilter) Matches(order *models.Order) bool {
+	if f.UserID != nil && order.UserID != *f.UserID {
+		return false
+	}
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, status := range f.Statuses {
+			if order.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.CreatedFrom != nil && order.CreatedAt.Before(*f.CreatedFrom) {
+		return false
+	}
+	if f.CreatedTo != nil && order.CreatedAt.After(*f.CreatedTo) {
+		return false
+	}
+	if f.Currency != "" && order.Currency != f.Currency {
+		return false
+	}
+	if f.MinAmount != nil && order.TotalAmount.Amount < *f.MinAmount {
+		return false
+	}
+	if f.MaxAmount != nil && order.TotalAmount.Amount > *f.MaxAmount {
+		return false
+	}
+	if f.ProductName != "" {
+		product := strings.ToLower(f.ProductName)
+		for _, item := range order.Items {
+			if strings.Contains(strings.ToLower(item.ProductName), product) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
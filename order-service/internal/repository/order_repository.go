@@ -2,6 +2,7 @@ package repository
 
 import (
 	"errors"
+	"order-service/internal/events"
 	"order-service/models"
 	"sort"
 	"sync"
@@ -9,26 +10,45 @@ import (
 	"github.com/google/uuid"
 )
 
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrOrderVersionConflict — заказ изменён после того, как его прочитали
+var ErrOrderVersionConflict = errors.New("order has been modified by another request")
+
+// Create и Update записывают события pending в outbox вместе с заказом: событие
+// появляется, только если изменение сохранено
 type OrderRepository interface {
-	Create(order *models.Order) error
+	Create(order *models.Order, pending ...*events.OrderEvent) error
 	FindByID(id uuid.UUID) (*models.Order, error)
 	FindByUserID(userID uuid.UUID, page, limit int, sortBy string) ([]*models.Order, int, error)
-	Update(order *models.Order) error
+	FindAll(filter OrderFilter, page, limit int, sortBy string) ([]*models.Order, int, error)
+	// FindPage — выборка по курсору: до limit заказов после seek (nil — с начала)
+	// и признак, что в направлении обхода есть ещё заказы
+	FindPage(filter OrderFilter, sortBy string, seek *OrderSeek, limit int) ([]*models.Order, bool, error)
+	// Update сохраняет заказ, только если в хранилище та же версия, что в order, и увеличивает
+	// order.Version; иначе ErrOrderVersionConflict. Вместе с заказом к его резервам применяется settlement.
+	Update(order *models.Order, settlement Settlement, pending ...*events.OrderEvent) error
 	Delete(id uuid.UUID) error
 }
 
+// InMemoryOrderRepository хранит копии заказов: изменения вызывающего не видны
+// другим, пока не сохранены через Update
 type InMemoryOrderRepository struct {
-	orders map[uuid.UUID]*models.Order
-	mu     sync.RWMutex
+	orders    map[uuid.UUID]*models.Order
+	outbox    *InMemoryOutboxRepository
+	inventory *InMemoryInventoryRepository
+	mu        sync.RWMutex
 }
 
-func NewInMemoryOrderRepository() *InMemoryOrderRepository {
+func NewInMemoryOrderRepository(outbox *InMemoryOutboxRepository, inventory *InMemoryInventoryRepository) *InMemoryOrderRepository {
 	return &InMemoryOrderRepository{
-		orders: make(map[uuid.UUID]*models.Order),
+		orders:    make(map[uuid.UUID]*models.Order),
+		outbox:    outbox,
+		inventory: inventory,
 	}
 }
 
-func (r *InMemoryOrderRepository) Create(order *models.Order) error {
+func (r *InMemoryOrderRepository) Create(order *models.Order, pending ...*events.OrderEvent) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -36,7 +56,8 @@ func (r *InMemoryOrderRepository) Create(order *models.Order) error {
 		return errors.New("order already exists")
 	}
 
-	r.orders[order.ID] = order
+	r.orders[order.ID] = copyOrder(order)
+	r.outbox.add(order, pending)
 	return nil
 }
 
@@ -46,41 +67,29 @@ func (r *InMemoryOrderRepository) FindByID(id uuid.UUID) (*models.Order, error)
 
 	order, exists := r.orders[id]
 	if !exists {
-		return nil, errors.New("order not found")
+		return nil, ErrOrderNotFound
 	}
-	return order, nil
+	return copyOrder(order), nil
 }
 
 func (r *InMemoryOrderRepository) FindByUserID(userID uuid.UUID, page, limit int, sortBy string) ([]*models.Order, int, error) {
+	return r.FindAll(OrderFilter{UserID: &userID}, page, limit, sortBy)
+}
+
+func (r *InMemoryOrderRepository) FindAll(filter OrderFilter, page, limit int, sortBy string) ([]*models.Order, int, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	var userOrders []*models.Order
+	var orders []*models.Order
 	for _, order := range r.orders {
-		if order.UserID == userID {
-			userOrders = append(userOrders, order)
+		if filter.Matches(order) {
+			orders = append(orders, copyOrder(order))
 		}
 	}
 
-	if sortBy == "createdAt_desc" {
-		sort.Slice(userOrders, func(i, j int) bool {
-			return userOrders[i].CreatedAt.After(userOrders[j].CreatedAt)
-		})
-	} else if sortBy == "createdAt_asc" {
-		sort.Slice(userOrders, func(i, j int) bool {
-			return userOrders[i].CreatedAt.Before(userOrders[j].CreatedAt)
-		})
-	} else if sortBy == "totalAmount_desc" {
-		sort.Slice(userOrders, func(i, j int) bool {
-			return userOrders[i].TotalAmount > userOrders[j].TotalAmount
-		})
-	} else if sortBy == "totalAmount_asc" {
-		sort.Slice(userOrders, func(i, j int) bool {
-			return userOrders[i].TotalAmount < userOrders[j].TotalAmount
-		})
-	}
+	sortOrders(orders, sortBy)
 
-	total := len(userOrders)
+	total := len(orders)
 	start := (page - 1) * limit
 	end := start + limit
 
@@ -91,18 +100,57 @@ func (r *InMemoryOrderRepository) FindByUserID(userID uuid.UUID, page, limit int
 		end = total
 	}
 
-	return userOrders[start:end], total, nil
+	return orders[start:end], total, nil
+}
+
+func (r *InMemoryOrderRepository) FindPage(filter OrderFilter, sortBy string, seek *OrderSeek, limit int) ([]*models.Order, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	less := orderLess(sortBy)
+	orders := []*models.Order{}
+	for _, order := range r.orders {
+		if !filter.Matches(order) {
+			continue
+		}
+		if seek != nil {
+			key := &models.Order{ID: seek.ID, CreatedAt: seek.CreatedAt, TotalAmount: models.Money{Amount: seek.TotalAmount}}
+			if seek.Backward && !less(order, key) || !seek.Backward && !less(key, order) {
+				continue
+			}
+		}
+		orders = append(orders, copyOrder(order))
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return less(orders[i], orders[j])
+	})
+
+	if len(orders) <= limit {
+		return orders, false, nil
+	}
+	if seek != nil && seek.Backward {
+		// Назад берутся ближайшие к курсору, то есть последние
+		return orders[len(orders)-limit:], true, nil
+	}
+	return orders[:limit], true, nil
 }
 
-func (r *InMemoryOrderRepository) Update(order *models.Order) error {
+func (r *InMemoryOrderRepository) Update(order *models.Order, settlement Settlement, pending ...*events.OrderEvent) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.orders[order.ID]; !exists {
-		return errors.New("order not found")
+	stored, exists := r.orders[order.ID]
+	if !exists {
+		return ErrOrderNotFound
+	}
+	if stored.Version != order.Version {
+		return ErrOrderVersionConflict
 	}
 
-	r.orders[order.ID] = order
+	r.inventory.apply(order.ID, settlement, order.UpdatedAt)
+	order.Version++
+	r.orders[order.ID] = copyOrder(order)
+	r.outbox.add(order, pending)
 	return nil
 }
 
@@ -111,9 +159,63 @@ func (r *InMemoryOrderRepository) Delete(id uuid.UUID) error {
 	defer r.mu.Unlock()
 
 	if _, exists := r.orders[id]; !exists {
-		return errors.New("order not found")
+		return ErrOrderNotFound
 	}
 
 	delete(r.orders, id)
 	return nil
 }
+
+func copyOrder(order *models.Order) *models.Order {
+	copied := *order
+	copied.Items = append([]models.OrderItem(nil), order.Items...)
+	return &copied
+}
+
+func sortOrders(orders []*models.Order, sortBy string) {
+	less := orderLess(sortBy)
+	sort.Slice(orders, func(i, j int) bool {
+		return less(orders[i], orders[j])
+	})
+}
+
+// orderLess — порядок сортировки с ID для равных значений, как в SQL-хранилище
+func orderLess(sortBy string) func(a, b *models.Order) bool {
+	byID := func(a, b *models.Order, desc bool) bool {
+		if desc {
+			return a.ID.String() > b.ID.String()
+		}
+		return a.ID.String() < b.ID.String()
+	}
+
+	switch sortBy {
+	case "createdAt_asc":
+		return func(a, b *models.Order) bool {
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.Before(b.CreatedAt)
+			}
+			return byID(a, b, false)
+		}
+	case "totalAmount_desc":
+		return func(a, b *models.Order) bool {
+			if a.TotalAmount.Amount != b.TotalAmount.Amount {
+				return a.TotalAmount.Amount > b.TotalAmount.Amount
+			}
+			return byID(a, b, true)
+		}
+	case "totalAmount_asc":
+		return func(a, b *models.Order) bool {
+			if a.TotalAmount.Amount != b.TotalAmount.Amount {
+				return a.TotalAmount.Amount < b.TotalAmount.Amount
+			}
+			return byID(a, b, false)
+		}
+	default:
+		return func(a, b *models.Order) bool {
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+			return byID(a, b, true)
+		}
+	}
+}
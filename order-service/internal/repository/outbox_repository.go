@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"errors"
+	"order-service/internal/events"
+	"order-service/models"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent — событие заказа, записанное в одной транзакции с изменением заказа.
+// Публикует его OutboxRelay; время записи — Event.Timestamp.
+type OutboxEvent struct {
+	Event   *events.OrderEvent
+	OrderID uuid.UUID
+	// Версия заказа после изменения: события одного заказа публикуются по возрастанию версии
+	OrderVersion int64
+	Attempts     int
+	LastError    string
+	DispatchedAt *time.Time
+}
+
+// OutboxStats — очередь неопубликованных событий
+type OutboxStats struct {
+	Pending         int
+	OldestPendingAt *time.Time
+}
+
+type OutboxRepository interface {
+	// Pending возвращает до limit неопубликованных событий в порядке записи
+	Pending(limit int) ([]*OutboxEvent, error)
+	MarkDispatched(eventID uuid.UUID, now time.Time) error
+	// MarkFailed считает неудачную попытку публикации
+	MarkFailed(eventID uuid.UUID, reason string) error
+	Stats() (*OutboxStats, error)
+	// DeleteDispatched удаляет события, опубликованные раньше before
+	DeleteDispatched(before time.Time) (int, error)
+}
+
+type InMemoryOutboxRepository struct {
+	// В порядке записи
+	events []*OutboxEvent
+	mu     sync.Mutex
+}
+
+func NewInMemoryOutboxRepository() *InMemoryOutboxRepository {
+	return &InMemoryOutboxRepository{}
+}
+
+// add вызывается InMemoryOrderRepository вместе с сохранением заказа
+func (r *InMemoryOutboxRepository) add(order *models.Order, pending []*events.OrderEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, event := range pending {
+		r.events = append(r.events, &OutboxEvent{Event: event, OrderID: order.ID, OrderVersion: order.Version})
+	}
+}
+
+func (r *InMemoryOutboxRepository) Pending(limit int) ([]*OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := []*OutboxEvent{}
+	for _, stored := range r.events {
+		if len(pending) == limit {
+			break
+		}
+		if stored.DispatchedAt == nil {
+			copied := *stored
+			pending = append(pending, &copied)
+		}
+	}
+	return pending, nil
+}
+
+func (r *InMemoryOutboxRepository) MarkDispatched(eventID uuid.UUID, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, err := r.find(eventID)
+	if err != nil {
+		return err
+	}
+	if stored.DispatchedAt == nil {
+		stored.DispatchedAt = &now
+	}
+	return nil
+}
+
+func (r *InMemoryOutboxRepository) MarkFailed(eventID uuid.UUID, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, err := r.find(eventID)
+	if err != nil {
+		return err
+	}
+	stored.Attempts++
+	stored.LastError = reason
+	return nil
+}
+
+func (r *InMemoryOutboxRepository) Stats() (*OutboxStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := &OutboxStats{}
+	for _, stored := range r.events {
+		if stored.DispatchedAt != nil {
+			continue
+		}
+		stats.Pending++
+		if stats.OldestPendingAt == nil || stored.Event.Timestamp.Before(*stats.OldestPendingAt) {
+			timestamp := stored.Event.Timestamp
+			stats.OldestPendingAt = &timestamp
+		}
+	}
+	return stats, nil
+}
+
+func (r *InMemoryOutboxRepository) DeleteDispatched(before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.events[:0]
+	for _, stored := range r.events {
+		if stored.DispatchedAt == nil || !stored.DispatchedAt.Before(before) {
+			kept = append(kept, stored)
+		}
+	}
+	deleted := len(r.events) - len(kept)
+	r.events = kept
+	return deleted, nil
+}
+
+func (r *InMemoryOutboxRepository) find(eventID uuid.UUID) (*OutboxEvent, error) {
+	for _, stored := range r.events {
+		if stored.Event.ID == eventID {
+			return stored, nil
+		}
+	}
+	return nil, errors.New("outbox event not found")
+}
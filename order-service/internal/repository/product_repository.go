@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"errors"
+	"order-service/models"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrProductExists   = errors.New("product with this sku already exists")
+)
+
+type ProductRepository interface {
+	Create(product *models.Product) error
+	FindBySKU(sku string) (*models.Product, error)
+	// FindBySKUs возвращает найденные товары по SKU; отсутствующих в результате нет
+	FindBySKUs(skus []string) (map[string]*models.Product, error)
+	// FindAll — товары по SKU; includeInactive — вместе со скрытыми из каталога
+	FindAll(includeInactive bool, page, limit int) ([]*models.Product, int, error)
+	Update(product *models.Product) error
+	Delete(sku string) error
+}
+
+type InMemoryProductRepository struct {
+	products map[string]*models.Product
+	mu       sync.RWMutex
+}
+
+func NewInMemoryProductRepository() *InMemoryProductRepository {
+	return &InMemoryProductRepository{
+		products: make(map[string]*models.Product),
+	}
+}
+
+func (r *InMemoryProductRepository) Create(product *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.products[product.SKU]; exists {
+		return ErrProductExists
+	}
+
+	r.products[product.SKU] = product
+	return nil
+}
+
+func (r *InMemoryProductRepository) FindBySKU(sku string) (*models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, exists := r.products[sku]
+	if !exists {
+		return nil, ErrProductNotFound
+	}
+	return product, nil
+}
+
+func (r *InMemoryProductRepository) FindBySKUs(skus []string) (map[string]*models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	products := make(map[string]*models.Product, len(skus))
+	for _, sku := range skus {
+		if product, exists := r.products[sku]; exists {
+			products[sku] = product
+		}
+	}
+	return products, nil
+}
+
+func (r *InMemoryProductRepository) FindAll(includeInactive bool, page, limit int) ([]*models.Product, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	products := []*models.Product{}
+	for _, product := range r.products {
+		if includeInactive || product.Active {
+			products = append(products, product)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].SKU < products[j].SKU
+	})
+
+	total := len(products)
+	start := (page - 1) * limit
+	end := start + limit
+
+	if start > total {
+		return []*models.Product{}, total, nil
+	}
+	if end > total {
+		end = total
+	}
+
+	return products[start:end], total, nil
+}
+
+func (r *InMemoryProductRepository) Update(product *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.products[product.SKU]; !exists {
+		return ErrProductNotFound
+	}
+
+	r.products[product.SKU] = product
+	return nil
+}
+
+func (r *InMemoryProductRepository) Delete(sku string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.products[sku]; !exists {
+		return ErrProductNotFound
+	}
+
+	delete(r.products, sku)
+	return nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type SQLIdempotencyRepository struct {
+	db *sql.DB
+}
+
+// NewSQLIdempotencyRepository ожидает схему, созданную миграциями (migrations)
+func NewSQLIdempotencyRepository(db *sql.DB) *SQLIdempotencyRepository {
+	return &SQLIdempotencyRepository{db: db}
+}
+
+// Start полагается на первичный ключ (user_id, idempotency_key): из параллельных
+// запросов с одним ключом запись создаёт только один
+func (r *SQLIdempotencyRepository) Start(record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	_, err := r.db.Exec(
+		`INSERT INTO idempotency_keys (user_id, idempotency_key, owner, fingerprint, completed, response_status, response_headers, response_body, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, FALSE, 0, '', '', $5, $6)`,
+		record.UserID.String(), record.Key, record.Owner, record.Fingerprint, record.CreatedAt.UTC(), record.ExpiresAt.UTC(),
+	)
+	if err == nil {
+		return nil, nil
+	}
+	if !isUniqueViolation(err) {
+		return nil, err
+	}
+
+	existing := IdempotencyRecord{UserID: record.UserID, Key: record.Key}
+	var headers, body string
+	err = r.db.QueryRow(
+		`SELECT owner, fingerprint, completed, response_status, response_headers, response_body, created_at, expires_at
+		FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2`,
+		record.UserID.String(), record.Key,
+	).Scan(&existing.Owner, &existing.Fingerprint, &existing.Completed, &existing.ResponseStatus, &headers, &body, &existing.CreatedAt, &existing.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if headers != "" {
+		if err := json.Unmarshal([]byte(headers), &existing.ResponseHeaders); err != nil {
+			return nil, fmt.Errorf("invalid stored response headers: %w", err)
+		}
+	}
+	existing.ResponseBody = []byte(body)
+	return &existing, nil
+}
+
+// Replace — условный UPDATE по владельцу: из параллельных повторов прерванную запись занимает один
+func (r *SQLIdempotencyRepository) Replace(stale, record *IdempotencyRecord) (bool, error) {
+	result, err := r.db.Exec(
+		`UPDATE idempotency_keys SET owner = $1, fingerprint = $2, completed = FALSE, response_status = 0, response_headers = '', response_body = '',
+		created_at = $3, expires_at = $4
+		WHERE user_id = $5 AND idempotency_key = $6 AND owner = $7`,
+		record.Owner, record.Fingerprint, record.CreatedAt.UTC(), record.ExpiresAt.UTC(), record.UserID.String(), record.Key, stale.Owner,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *SQLIdempotencyRepository) Complete(record *IdempotencyRecord) error {
+	headers, err := json.Marshal(record.ResponseHeaders)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(
+		`UPDATE idempotency_keys SET completed = TRUE, response_status = $1, response_headers = $2, response_body = $3
+		WHERE user_id = $4 AND idempotency_key = $5 AND owner = $6`,
+		record.ResponseStatus, string(headers), string(record.ResponseBody), record.UserID.String(), record.Key, record.Owner,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrIdempotencyRecordLost
+	}
+	return nil
+}
+
+func (r *SQLIdempotencyRepository) Release(record *IdempotencyRecord) error {
+	_, err := r.db.Exec(
+		`DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND owner = $3`,
+		record.UserID.String(), record.Key, record.Owner,
+	)
+	return err
+}
+
+func (r *SQLIdempotencyRepository) DeleteExpired(now time.Time) (int, error) {
+	result, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
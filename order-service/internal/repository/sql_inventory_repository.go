@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"order-service/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SQLInventoryRepository struct {
+	db *sql.DB
+}
+
+// NewSQLInventoryRepository ожидает схему, созданную миграциями (migrations)
+func NewSQLInventoryRepository(db *sql.DB) *SQLInventoryRepository {
+	return &SQLInventoryRepository{db: db}
+}
+
+func (r *SQLInventoryRepository) GetStock(sku string) (*models.StockLevel, error) {
+	return getStock(r.db, sku)
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func getStock(q queryRower, sku string) (*models.StockLevel, error) {
+	level := models.StockLevel{SKU: sku}
+	err := q.QueryRow(
+		`SELECT on_hand, reserved, updated_at FROM stock_levels WHERE sku = $1`, sku,
+	).Scan(&level.OnHand, &level.Reserved, &level.UpdatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	level.Available = level.OnHand - level.Reserved
+	return &level, nil
+}
+
+func (r *SQLInventoryRepository) SetStock(sku string, onHand int, now time.Time) (*models.StockLevel, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE stock_levels SET on_hand = $1, updated_at = $2 WHERE sku = $3 AND reserved <= $1`,
+		onHand, now.UTC(), sku,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 0 {
+		level, err := getStock(tx, sku)
+		if err != nil {
+			return nil, err
+		}
+		if level.Reserved > onHand {
+			return nil, ErrStockBelowReserved
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO stock_levels (sku, on_hand, reserved, updated_at) VALUES ($1, $2, 0, $3)`,
+			sku, onHand, now.UTC(),
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	level, err := getStock(tx, sku)
+	if err != nil {
+		return nil, err
+	}
+	return level, tx.Commit()
+}
+
+// Reserve увеличивает reserved условным UPDATE: остаток проверяется и удерживается одной
+// операцией, поэтому параллельные заказы не продадут больше, чем есть на складе
+func (r *SQLInventoryRepository) Reserve(reservations []*models.Reservation) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var shortages []models.OutOfStockItem
+	for _, reservation := range reservations {
+		result, err := tx.Exec(
+			`UPDATE stock_levels SET reserved = reserved + $1, updated_at = $2 WHERE sku = $3 AND on_hand - reserved >= $1`,
+			reservation.Quantity, reservation.CreatedAt.UTC(), reservation.SKU,
+		)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			level, err := getStock(tx, reservation.SKU)
+			if err != nil {
+				return err
+			}
+			shortages = append(shortages, models.OutOfStockItem{
+				SKU:       reservation.SKU,
+				Requested: reservation.Quantity,
+				Available: level.Available,
+			})
+		}
+	}
+	if len(shortages) > 0 {
+		return &models.OutOfStockError{Items: shortages}
+	}
+
+	for _, reservation := range reservations {
+		var expiresAt interface{}
+		if reservation.ExpiresAt != nil {
+			expiresAt = reservation.ExpiresAt.UTC()
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO stock_reservations (order_id, sku, quantity, status, expires_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			reservation.OrderID.String(), reservation.SKU, reservation.Quantity, string(reservation.Status),
+			expiresAt, reservation.CreatedAt.UTC(), reservation.UpdatedAt.UTC(),
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLInventoryRepository) FindReservations(orderID uuid.UUID) ([]*models.Reservation, error) {
+	rows, err := r.db.Query(
+		`SELECT order_id, sku, quantity, status, expires_at, created_at, updated_at FROM stock_reservations
+		WHERE order_id = $1 ORDER BY sku`,
+		orderID.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservations := []*models.Reservation{}
+	for rows.Next() {
+		var reservation models.Reservation
+		var id, status string
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&id, &reservation.SKU, &reservation.Quantity, &status, &expiresAt, &reservation.CreatedAt, &reservation.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if reservation.OrderID, err = uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("invalid order id %q: %w", id, err)
+		}
+		reservation.Status = models.ReservationStatus(status)
+		if expiresAt.Valid {
+			reservation.ExpiresAt = &expiresAt.Time
+		}
+		reservations = append(reservations, &reservation)
+	}
+	return reservations, rows.Err()
+}
+
+func (r *SQLInventoryRepository) Release(orderID uuid.UUID, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := settleReservations(tx, orderID, SettleRelease, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLInventoryRepository) FindExpired(now time.Time) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(
+		`SELECT DISTINCT order_id FROM stock_reservations WHERE status = $1 AND expires_at <= $2`,
+		string(models.ReservationActive), now.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orderIDs []uuid.UUID
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		orderID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid order id %q: %w", id, err)
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+	return orderIDs, rows.Err()
+}
+
+// settleReservations меняет резервы заказа в транзакции, которая сохраняет заказ
+func settleReservations(tx *sql.Tx, orderID uuid.UUID, settlement Settlement, now time.Time) error {
+	switch settlement {
+	case SettleConfirm:
+		_, err := tx.Exec(
+			`UPDATE stock_reservations SET expires_at = NULL, updated_at = $1 WHERE order_id = $2 AND status = $3`,
+			now.UTC(), orderID.String(), string(models.ReservationActive),
+		)
+		return err
+	case SettleCommit:
+		return finishReservations(tx, orderID, models.ReservationCommitted, now)
+	case SettleRelease:
+		return finishReservations(tx, orderID, models.ReservationReleased, now)
+	}
+	return nil
+}
+
+// finishReservations закрывает активные резервы заказа: committed списывает товар с остатка,
+// released возвращает в доступный. Статус резерва меняется условным UPDATE, поэтому
+// параллельный вызов не спишет его второй раз.
+func finishReservations(tx *sql.Tx, orderID uuid.UUID, status models.ReservationStatus, now time.Time) error {
+	type pending struct {
+		sku      string
+		quantity int
+	}
+	rows, err := tx.Query(
+		`SELECT sku, quantity FROM stock_reservations WHERE order_id = $1 AND status = $2`,
+		orderID.String(), string(models.ReservationActive),
+	)
+	if err != nil {
+		return err
+	}
+	var reservations []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.sku, &p.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		reservations = append(reservations, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stockUpdate := `UPDATE stock_levels SET reserved = reserved - $1, updated_at = $2 WHERE sku = $3`
+	if status == models.ReservationCommitted {
+		stockUpdate = `UPDATE stock_levels SET reserved = reserved - $1, on_hand = on_hand - $1, updated_at = $2 WHERE sku = $3`
+	}
+
+	for _, p := range reservations {
+		result, err := tx.Exec(
+			`UPDATE stock_reservations SET status = $1, expires_at = NULL, updated_at = $2
+			WHERE order_id = $3 AND sku = $4 AND status = $5`,
+			string(status), now.UTC(), orderID.String(), p.sku, string(models.ReservationActive),
+		)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			continue
+		}
+		if _, err := tx.Exec(stockUpdate, p.quantity, now.UTC(), p.sku); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"order-service/internal/events"
+	"order-service/models"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Сортировки, которые принимает FindByUserID; id — чтобы порядок страниц был стабильным
+var orderSorts = map[string]string{
+	"createdAt_desc":   "created_at DESC, id DESC",
+	"createdAt_asc":    "created_at ASC, id ASC",
+	"totalAmount_desc": "total_amount_minor DESC, id DESC",
+	"totalAmount_asc":  "total_amount_minor ASC, id ASC",
+}
+
+// Ключ сортировки для выборки по курсору
+var orderSeekKeys = map[string]struct {
+	column string
+	desc   bool
+}{
+	"createdAt_desc":   {column: "created_at", desc: true},
+	"createdAt_asc":    {column: "created_at"},
+	"totalAmount_desc": {column: "total_amount_minor", desc: true},
+	"totalAmount_asc":  {column: "total_amount_minor"},
+}
+
+type SQLOrderRepository struct {
+	db *sql.DB
+}
+
+// NewSQLOrderRepository ожидает схему, созданную миграциями (migrations)
+func NewSQLOrderRepository(db *sql.DB) *SQLOrderRepository {
+	return &SQLOrderRepository{db: db}
+}
+
+func (r *SQLOrderRepository) Create(order *models.Order, pending ...*events.OrderEvent) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO orders (id, user_id, status, currency, total_amount_minor, version, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		order.ID.String(), order.UserID.String(), string(order.Status), order.Currency, order.TotalAmount.Amount, order.Version, order.CreatedAt.UTC(), order.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.New("order already exists")
+		}
+		return err
+	}
+	if err := insertItems(tx, order); err != nil {
+		return err
+	}
+	if err := insertOutboxEvents(tx, order.ID, order.Version, pending); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLOrderRepository) FindByID(id uuid.UUID) (*models.Order, error) {
+	order, err := scanOrder(r.db.QueryRow(
+		`SELECT id, user_id, status, currency, total_amount_minor, version, created_at, updated_at FROM orders WHERE id = $1`, id.String(),
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadItems([]*models.Order{order}); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (r *SQLOrderRepository) FindByUserID(userID uuid.UUID, page, limit int, sortBy string) ([]*models.Order, int, error) {
+	return r.FindAll(OrderFilter{UserID: &userID}, page, limit, sortBy)
+}
+
+func (r *SQLOrderRepository) FindAll(filter OrderFilter, page, limit int, sortBy string) ([]*models.Order, int, error) {
+	orderBy, ok := orderSorts[sortBy]
+	if !ok {
+		orderBy = orderSorts["createdAt_desc"]
+	}
+	where, args := filterConditions(filter)
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM orders`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(
+		fmt.Sprintf(`SELECT id, user_id, status, currency, total_amount_minor, version, created_at, updated_at FROM orders%s
+		ORDER BY %s LIMIT $%d OFFSET $%d`, where, orderBy, len(args)+1, len(args)+2),
+		append(args, limit, (page-1)*limit)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	orders := []*models.Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.loadItems(orders); err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func (r *SQLOrderRepository) FindPage(filter OrderFilter, sortBy string, seek *OrderSeek, limit int) ([]*models.Order, bool, error) {
+	key, ok := orderSeekKeys[sortBy]
+	if !ok {
+		key = orderSeekKeys["createdAt_desc"]
+	}
+	where, args := filterConditions(filter)
+
+	// Назад по выборке — обратный порядок, результат потом разворачивается
+	desc := key.desc
+	if seek != nil && seek.Backward {
+		desc = !desc
+	}
+	op, direction := ">", "ASC"
+	if desc {
+		op, direction = "<", "DESC"
+	}
+
+	if seek != nil {
+		var value interface{} = seek.CreatedAt.UTC()
+		if key.column == "total_amount_minor" {
+			value = seek.TotalAmount
+		}
+		args = append(args, value, seek.ID.String())
+		condition := fmt.Sprintf("(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND id %[2]s $%[4]d))", key.column, op, len(args)-1, len(args))
+		if where == "" {
+			where = " WHERE " + condition
+		} else {
+			where += " AND " + condition
+		}
+	}
+
+	rows, err := r.db.Query(
+		fmt.Sprintf(`SELECT id, user_id, status, currency, total_amount_minor, version, created_at, updated_at FROM orders%s
+		ORDER BY %s %s, id %s LIMIT $%d`, where, key.column, direction, direction, len(args)+1),
+		append(args, limit+1)...,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	orders := []*models.Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, false, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(orders) > limit
+	if hasMore {
+		orders = orders[:limit]
+	}
+	if seek != nil && seek.Backward {
+		for i, j := 0, len(orders)-1; i < j; i, j = i+1, j-1 {
+			orders[i], orders[j] = orders[j], orders[i]
+		}
+	}
+
+	if err := r.loadItems(orders); err != nil {
+		return nil, false, err
+	}
+	return orders, hasMore, nil
+}
+
+// filterConditions строит WHERE для OrderFilter (то же, что OrderFilter.Matches)
+func filterConditions(filter OrderFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.UserID != nil {
+		conditions = append(conditions, "user_id = "+arg(filter.UserID.String()))
+	}
+	if len(filter.Statuses) > 0 {
+		placeholders := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			placeholders[i] = arg(string(status))
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if filter.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= "+arg(filter.CreatedFrom.UTC()))
+	}
+	if filter.CreatedTo != nil {
+		conditions = append(conditions, "created_at <= "+arg(filter.CreatedTo.UTC()))
+	}
+	if filter.Currency != "" {
+		conditions = append(conditions, "currency = "+arg(filter.Currency))
+	}
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "total_amount_minor >= "+arg(*filter.MinAmount))
+	}
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "total_amount_minor <= "+arg(*filter.MaxAmount))
+	}
+	if filter.ProductName != "" {
+		// % и _ в названии ищутся буквально
+		pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(filter.ProductName))
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id
+			AND lower(order_items.product_name) LIKE `+arg("%"+pattern+"%")+` ESCAPE '\')`)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Update — сравнение с записью по версии: из параллельных изменений одной версии проходит одно,
+// и только оно меняет резервы заказа
+func (r *SQLOrderRepository) Update(order *models.Order, settlement Settlement, pending ...*events.OrderEvent) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE orders SET status = $1, total_amount_minor = $2, updated_at = $3, version = version + 1 WHERE id = $4 AND version = $5`,
+		string(order.Status), order.TotalAmount.Amount, order.UpdatedAt.UTC(), order.ID.String(), order.Version,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM orders WHERE id = $1)`, order.ID.String()).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrOrderVersionConflict
+		}
+		return ErrOrderNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM order_items WHERE order_id = $1`, order.ID.String()); err != nil {
+		return err
+	}
+	if err := insertItems(tx, order); err != nil {
+		return err
+	}
+	if err := settleReservations(tx, order.ID, settlement, order.UpdatedAt); err != nil {
+		return err
+	}
+	if err := insertOutboxEvents(tx, order.ID, order.Version+1, pending); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	order.Version++
+	return nil
+}
+
+func (r *SQLOrderRepository) Delete(id uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Позиции удаляются явно: в SQLite каскад работает только с включёнными foreign_keys
+	if _, err := tx.Exec(`DELETE FROM order_items WHERE order_id = $1`, id.String()); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM orders WHERE id = $1`, id.String())
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrOrderNotFound
+	}
+
+	return tx.Commit()
+}
+
+// loadItems загружает позиции для страницы заказов одним запросом
+func (r *SQLOrderRepository) loadItems(orders []*models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*models.Order, len(orders))
+	placeholders := make([]string, 0, len(orders))
+	args := make([]interface{}, 0, len(orders))
+	for i, order := range orders {
+		order.Items = []models.OrderItem{}
+		byID[order.ID.String()] = order
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		args = append(args, order.ID.String())
+	}
+
+	rows, err := r.db.Query(
+		`SELECT order_id, sku, product_name, quantity, price_minor FROM order_items
+		WHERE order_id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY order_id, position`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID string
+		var item models.OrderItem
+		if err := rows.Scan(&orderID, &item.SKU, &item.ProductName, &item.Quantity, &item.Price.Amount); err != nil {
+			return err
+		}
+		if order, exists := byID[orderID]; exists {
+			item.Price.Currency = order.Currency
+			order.Items = append(order.Items, item)
+		}
+	}
+	return rows.Err()
+}
+
+func insertItems(tx *sql.Tx, order *models.Order) error {
+	for i, item := range order.Items {
+		if _, err := tx.Exec(
+			`INSERT INTO order_items (order_id, position, sku, product_name, quantity, price_minor) VALUES ($1, $2, $3, $4, $5, $6)`,
+			order.ID.String(), i, item.SKU, item.ProductName, item.Quantity, item.Price.Amount,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOrder(row rowScanner) (*models.Order, error) {
+	var order models.Order
+	var id, userID, status string
+	if err := row.Scan(&id, &userID, &status, &order.Currency, &order.TotalAmount.Amount, &order.Version, &order.CreatedAt, &order.UpdatedAt); err != nil {
+		return nil, err
+	}
+	order.TotalAmount.Currency = order.Currency
+
+	var err error
+	if order.ID, err = uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("invalid order id %q: %w", id, err)
+	}
+	if order.UserID, err = uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid user id %q: %w", userID, err)
+	}
+	order.Status = models.OrderStatus(status)
+	return &order, nil
+}
+
+// isUniqueViolation распознаёт нарушение уникальности в SQLite и PostgreSQL (SQLSTATE 23505)
+func isUniqueViolation(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "UNIQUE constraint failed") ||
+		strings.Contains(message, "duplicate key value") ||
+		strings.Contains(message, "23505")
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/internal/events"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SQLOutboxRepository struct {
+	db *sql.DB
+}
+
+// NewSQLOutboxRepository ожидает схему, созданную миграциями (migrations)
+func NewSQLOutboxRepository(db *sql.DB) *SQLOutboxRepository {
+	return &SQLOutboxRepository{db: db}
+}
+
+// insertOutboxEvents пишет события в транзакции, которая сохраняет заказ
+func insertOutboxEvents(tx *sql.Tx, orderID uuid.UUID, orderVersion int64, pending []*events.OrderEvent) error {
+	for _, event := range pending {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO outbox_events (id, order_id, order_version, event_type, payload, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+			event.ID.String(), orderID.String(), orderVersion, string(event.Type), string(payload), event.Timestamp.UTC(),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pending упорядочивает по времени записи, а события одного заказа с одним временем — по версии
+func (r *SQLOutboxRepository) Pending(limit int) ([]*OutboxEvent, error) {
+	rows, err := r.db.Query(
+		`SELECT order_id, order_version, payload, attempts, last_error FROM outbox_events
+		WHERE dispatched_at IS NULL ORDER BY created_at, order_id, order_version LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pending := []*OutboxEvent{}
+	for rows.Next() {
+		var stored OutboxEvent
+		var orderID, payload string
+		if err := rows.Scan(&orderID, &stored.OrderVersion, &payload, &stored.Attempts, &stored.LastError); err != nil {
+			return nil, err
+		}
+		if stored.OrderID, err = uuid.Parse(orderID); err != nil {
+			return nil, fmt.Errorf("invalid order id %q: %w", orderID, err)
+		}
+		if err := json.Unmarshal([]byte(payload), &stored.Event); err != nil {
+			return nil, fmt.Errorf("invalid outbox payload of order %s: %w", orderID, err)
+		}
+		pending = append(pending, &stored)
+	}
+	return pending, rows.Err()
+}
+
+func (r *SQLOutboxRepository) MarkDispatched(eventID uuid.UUID, now time.Time) error {
+	_, err := r.db.Exec(
+		`UPDATE outbox_events SET dispatched_at = $1 WHERE id = $2 AND dispatched_at IS NULL`,
+		now.UTC(), eventID.String(),
+	)
+	return err
+}
+
+func (r *SQLOutboxRepository) MarkFailed(eventID uuid.UUID, reason string) error {
+	_, err := r.db.Exec(
+		`UPDATE outbox_events SET attempts = attempts + 1, last_error = $1 WHERE id = $2`,
+		reason, eventID.String(),
+	)
+	return err
+}
+
+func (r *SQLOutboxRepository) Stats() (*OutboxStats, error) {
+	stats := &OutboxStats{}
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM outbox_events WHERE dispatched_at IS NULL`).Scan(&stats.Pending); err != nil {
+		return nil, err
+	}
+	if stats.Pending == 0 {
+		return stats, nil
+	}
+
+	var oldest time.Time
+	err := r.db.QueryRow(
+		`SELECT created_at FROM outbox_events WHERE dispatched_at IS NULL ORDER BY created_at LIMIT 1`,
+	).Scan(&oldest)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Последнее событие опубликовали между запросами
+		return &OutboxStats{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stats.OldestPendingAt = &oldest
+	return stats, nil
+}
+
+func (r *SQLOutboxRepository) DeleteDispatched(before time.Time) (int, error) {
+	result, err := r.db.Exec(`DELETE FROM outbox_events WHERE dispatched_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"order-service/models"
+	"strings"
+)
+
+type SQLProductRepository struct {
+	db *sql.DB
+}
+
+// NewSQLProductRepository ожидает схему, созданную миграциями (migrations)
+func NewSQLProductRepository(db *sql.DB) *SQLProductRepository {
+	return &SQLProductRepository{db: db}
+}
+
+func (r *SQLProductRepository) Create(product *models.Product) error {
+	_, err := r.db.Exec(
+		`INSERT INTO products (sku, name, price_minor, currency, active, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		product.SKU, product.Name, product.Price.Amount, product.Currency, product.Active, product.CreatedAt.UTC(), product.UpdatedAt.UTC(),
+	)
+	if err != nil && isUniqueViolation(err) {
+		return ErrProductExists
+	}
+	return err
+}
+
+func (r *SQLProductRepository) FindBySKU(sku string) (*models.Product, error) {
+	product, err := scanProduct(r.db.QueryRow(
+		`SELECT sku, name, price_minor, currency, active, created_at, updated_at FROM products WHERE sku = $1`, sku,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProductNotFound
+	}
+	return product, err
+}
+
+func (r *SQLProductRepository) FindBySKUs(skus []string) (map[string]*models.Product, error) {
+	products := make(map[string]*models.Product, len(skus))
+	if len(skus) == 0 {
+		return products, nil
+	}
+
+	placeholders := make([]string, len(skus))
+	args := make([]interface{}, len(skus))
+	for i, sku := range skus {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = sku
+	}
+
+	rows, err := r.db.Query(
+		`SELECT sku, name, price_minor, currency, active, created_at, updated_at FROM products
+		WHERE sku IN (`+strings.Join(placeholders, ", ")+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products[product.SKU] = product
+	}
+	return products, rows.Err()
+}
+
+func (r *SQLProductRepository) FindAll(includeInactive bool, page, limit int) ([]*models.Product, int, error) {
+	var total int
+	if err := r.db.QueryRow(
+		`SELECT COUNT(*) FROM products WHERE $1 OR active`, includeInactive,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(
+		`SELECT sku, name, price_minor, currency, active, created_at, updated_at FROM products
+		WHERE $1 OR active ORDER BY sku LIMIT $2 OFFSET $3`,
+		includeInactive, limit, (page-1)*limit,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	products := []*models.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return products, total, nil
+}
+
+func (r *SQLProductRepository) Update(product *models.Product) error {
+	result, err := r.db.Exec(
+		`UPDATE products SET name = $1, price_minor = $2, currency = $3, active = $4, updated_at = $5 WHERE sku = $6`,
+		product.Name, product.Price.Amount, product.Currency, product.Active, product.UpdatedAt.UTC(), product.SKU,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+func (r *SQLProductRepository) Delete(sku string) error {
+	result, err := r.db.Exec(`DELETE FROM products WHERE sku = $1`, sku)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrProductNotFound
+	}
+	return nil
+}
+
+func scanProduct(row rowScanner) (*models.Product, error) {
+	var product models.Product
+	if err := row.Scan(
+		&product.SKU, &product.Name, &product.Price.Amount, &product.Currency,
+		&product.Active, &product.CreatedAt, &product.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	product.Price.Currency = product.Currency
+	return &product, nil
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"order-service/internal/repository"
+	"order-service/models"
+)
+
+// CatalogClient — источник названий и цен товаров для заказов
+type CatalogClient interface {
+	GetProducts(skus []string) (map[string]*models.Product, error)
+}
+
+// LocalCatalogClient читает каталог, который живёт в этом же сервисе.
+// Если каталог станет отдельным сервисом, его заменит HTTP-клиент, как HTTPUserClient.
+type LocalCatalogClient struct {
+	repo repository.ProductRepository
+}
+
+func NewLocalCatalogClient(repo repository.ProductRepository) *LocalCatalogClient {
+	return &LocalCatalogClient{repo: repo}
+}
+
+func (c *LocalCatalogClient) GetProducts(skus []string) (map[string]*models.Product, error) {
+	return c.repo.FindBySKUs(skus)
+}
@@ -2,64 +2,140 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log"
 	"order-service/internal/dto"
 	"order-service/internal/events"
 	"order-service/internal/repository"
 	"order-service/models"
+	"strings"
+	"time"
 
+	"github.com/ChrolloLucii/control-system/shared/etag"
 	"github.com/google/uuid"
 )
 
+// Сколько раз изменение без If-Match повторяется, если заказ успели изменить параллельно
+const maxUpdateAttempts = 3
+
 type OrderService interface {
 	CreateOrder(userID uuid.UUID, req *dto.CreateOrderRequest, token string) (*models.Order, error)
 	GetOrder(orderID, userID uuid.UUID, isAdmin bool) (*models.Order, error)
 	GetUserOrders(userID uuid.UUID, page, limit int, sortBy string) ([]*models.Order, int, error)
-	UpdateOrderStatus(orderID, userID uuid.UUID, status string, isAdmin bool) (*models.Order, error)
-	CancelOrder(orderID, userID uuid.UUID, isAdmin bool) (*models.Order, error)
+	ListOrders(filter repository.OrderFilter, page, limit int, sortBy string) ([]*models.Order, int, error)
+	GetUserOrdersPage(userID uuid.UUID, sortBy string, seek *repository.OrderSeek, limit int) ([]*models.Order, bool, error)
+	ListOrdersPage(filter repository.OrderFilter, sortBy string, seek *repository.OrderSeek, limit int) ([]*models.Order, bool, error)
+	// ifMatch — версии из If-Match; если заказ другой версии, repository.ErrOrderVersionConflict
+	UpdateOrderStatus(orderID, userID uuid.UUID, status string, isAdmin bool, ifMatch etag.Condition) (*models.Order, error)
+	CancelOrder(orderID, userID uuid.UUID, isAdmin bool, ifMatch etag.Condition) (*models.Order, error)
+	GetOrderTransitions(orderID, userID uuid.UUID, isAdmin bool) (*models.Order, []models.OrderStatus, error)
+	// ExpireReservations отменяет заказы, резерв которых истёк до взятия в работу
+	ExpireReservations(now time.Time) (int, error)
 }
 
+// События заказов записываются в outbox вместе с заказом, публикует их OutboxRelay
 type orderService struct {
-	repo           repository.OrderRepository
-	eventPublisher events.EventPublisher
-	userClient     UserClient
+	repo       repository.OrderRepository
+	userClient UserClient
+	catalog    CatalogClient
+	inventory  repository.InventoryRepository
+	// Сколько товар удерживается под заказ, пока тот не взят в работу
+	reservationTTL time.Duration
 }
 
-func NewOrderService(repo repository.OrderRepository, eventPublisher events.EventPublisher, userClient UserClient) OrderService {
+func NewOrderService(repo repository.OrderRepository, userClient UserClient, catalog CatalogClient, inventory repository.InventoryRepository, reservationTTL time.Duration) OrderService {
 	return &orderService{
 		repo:           repo,
-		eventPublisher: eventPublisher,
 		userClient:     userClient,
+		catalog:        catalog,
+		inventory:      inventory,
+		reservationTTL: reservationTTL,
 	}
 }
 
+// UnavailableProductsError — в заказе есть товары, которых нет в каталоге или которые скрыты
+type UnavailableProductsError struct {
+	SKUs []string
+}
+
+func (e *UnavailableProductsError) Error() string {
+	return "products are not available: " + strings.Join(e.SKUs, ", ")
+}
+
 func (s *orderService) CreateOrder(userID uuid.UUID, req *dto.CreateOrderRequest, token string) (*models.Order, error) {
 	exists, err := s.userClient.UserExists(userID, token)
 	if err != nil || !exists {
 		return nil, errors.New("user not found or invalid")
 	}
 
-	var items []models.OrderItem
-	for _, item := range req.Items {
-		items = append(items, models.OrderItem{
-			ProductName: item.ProductName,
-			Quantity:    item.Quantity,
-			Price:       item.Price,
-		})
+	items, currency, err := s.resolveItems(req)
+	if err != nil {
+		return nil, err
 	}
 
-	order := models.NewOrder(userID, items)
-
-	err = s.repo.Create(order)
+	order, err := models.NewOrder(userID, currency, items)
 	if err != nil {
 		return nil, err
 	}
 
-	event := events.NewOrderCreatedEvent(order)
-	s.eventPublisher.Publish(event)
+	// Товар резервируется до сохранения заказа: если сервис упадёт между шагами,
+	// резерв без заказа истечёт сам
+	if err := s.inventory.Reserve(models.NewReservations(order, order.CreatedAt.Add(s.reservationTTL))); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Create(order, events.NewOrderCreatedEvent(order))
+	if err != nil {
+		if releaseErr := s.inventory.Release(order.ID, time.Now()); releaseErr != nil {
+			log.Printf("Failed to release stock of unsaved order %s: %v", order.ID, releaseErr)
+		}
+		return nil, err
+	}
 
 	return order, nil
 }
 
+// resolveItems подставляет в позиции название и цену из каталога
+func (s *orderService) resolveItems(req *dto.CreateOrderRequest) ([]models.OrderItem, string, error) {
+	skus := make([]string, len(req.Items))
+	for i, item := range req.Items {
+		skus[i] = item.SKU
+	}
+	products, err := s.catalog.GetProducts(skus)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var unavailable []string
+	for _, sku := range skus {
+		if product, exists := products[sku]; !exists || !product.Active {
+			unavailable = append(unavailable, sku)
+		}
+	}
+	if len(unavailable) > 0 {
+		return nil, "", &UnavailableProductsError{SKUs: unavailable}
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = products[skus[0]].Currency
+	}
+	items := make([]models.OrderItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		product := products[item.SKU]
+		if product.Currency != currency {
+			return nil, "", fmt.Errorf("product %s is priced in %s, order currency is %s", product.SKU, product.Currency, currency)
+		}
+		items = append(items, models.OrderItem{
+			SKU:         product.SKU,
+			ProductName: product.Name,
+			Quantity:    item.Quantity,
+			Price:       product.Price,
+		})
+	}
+	return items, currency, nil
+}
+
 func (s *orderService) GetOrder(orderID, userID uuid.UUID, isAdmin bool) (*models.Order, error) {
 	order, err := s.repo.FindByID(orderID)
 	if err != nil {
@@ -77,53 +153,131 @@ func (s *orderService) GetUserOrders(userID uuid.UUID, page, limit int, sortBy s
 	return s.repo.FindByUserID(userID, page, limit, sortBy)
 }
 
-func (s *orderService) UpdateOrderStatus(orderID, userID uuid.UUID, status string, isAdmin bool) (*models.Order, error) {
-	order, err := s.repo.FindByID(orderID)
-	if err != nil {
-		return nil, err
-	}
-
-	if !isAdmin && order.UserID != userID {
-		return nil, errors.New("access denied")
-	}
+// ListOrders — заказы всех пользователей, только для администратора
+func (s *orderService) ListOrders(filter repository.OrderFilter, page, limit int, sortBy string) ([]*models.Order, int, error) {
+	return s.repo.FindAll(filter, page, limit, sortBy)
+}
 
-	oldStatus := order.Status
-	order.UpdateStatus(models.OrderStatus(status))
+func (s *orderService) GetUserOrdersPage(userID uuid.UUID, sortBy string, seek *repository.OrderSeek, limit int) ([]*models.Order, bool, error) {
+	return s.repo.FindPage(repository.OrderFilter{UserID: &userID}, sortBy, seek, limit)
+}
 
-	err = s.repo.Update(order)
-	if err != nil {
-		return nil, err
-	}
+func (s *orderService) ListOrdersPage(filter repository.OrderFilter, sortBy string, seek *repository.OrderSeek, limit int) ([]*models.Order, bool, error) {
+	return s.repo.FindPage(filter, sortBy, seek, limit)
+}
 
-	event := events.NewOrderStatusUpdatedEvent(order, oldStatus)
-	s.eventPublisher.Publish(event)
+func (s *orderService) UpdateOrderStatus(orderID, userID uuid.UUID, status string, isAdmin bool, ifMatch etag.Condition) (*models.Order, error) {
+	return s.updateOrder(orderID, userID, isAdmin, ifMatch, func(order *models.Order) (*events.OrderEvent, error) {
+		oldStatus := order.Status
+		if err := order.UpdateStatus(models.OrderStatus(status), isAdmin); err != nil {
+			return nil, err
+		}
+		return events.NewOrderStatusUpdatedEvent(order, oldStatus), nil
+	})
+}
 
-	return order, nil
+func (s *orderService) CancelOrder(orderID, userID uuid.UUID, isAdmin bool, ifMatch etag.Condition) (*models.Order, error) {
+	return s.updateOrder(orderID, userID, isAdmin, ifMatch, func(order *models.Order) (*events.OrderEvent, error) {
+		if err := order.Cancel(isAdmin); err != nil {
+			return nil, err
+		}
+		return events.NewOrderCancelledEvent(order), nil
+	})
 }
 
-func (s *orderService) CancelOrder(orderID, userID uuid.UUID, isAdmin bool) (*models.Order, error) {
-	order, err := s.repo.FindByID(orderID)
-	if err != nil {
-		return nil, err
-	}
+// updateOrder читает заказ, применяет change и сохраняет с проверкой версии вместе с событием,
+// которое вернул change. Если заказ успели изменить, а клиент не передал If-Match, изменение
+// повторяется на свежей версии — например, отмена параллельно со взятием в работу применится
+// к заказу в работе. С If-Match клиент сам решает, что делать с новой версией, и получает
+// repository.ErrOrderVersionConflict.
+func (s *orderService) updateOrder(orderID, userID uuid.UUID, isAdmin bool, ifMatch etag.Condition, change func(order *models.Order) (*events.OrderEvent, error)) (*models.Order, error) {
+	for attempt := 1; ; attempt++ {
+		order, err := s.repo.FindByID(orderID)
+		if err != nil {
+			return nil, err
+		}
 
-	if !isAdmin && order.UserID != userID {
-		return nil, errors.New("access denied")
+		if !isAdmin && order.UserID != userID {
+			return nil, errors.New("access denied")
+		}
+		if !ifMatch.Matches(order.Version) {
+			return nil, repository.ErrOrderVersionConflict
+		}
+
+		event, err := change(order)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.repo.Update(order, settlementFor(order.Status), event)
+		if errors.Is(err, repository.ErrOrderVersionConflict) && ifMatch == nil && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return order, nil
 	}
+}
 
-	if order.Status == models.StatusCompleted {
-		return nil, errors.New("cannot cancel completed order")
+func (s *orderService) GetOrderTransitions(orderID, userID uuid.UUID, isAdmin bool) (*models.Order, []models.OrderStatus, error) {
+	order, err := s.GetOrder(orderID, userID, isAdmin)
+	if err != nil {
+		return nil, nil, err
 	}
+	return order, order.AllowedTransitions(isAdmin), nil
+}
 
-	order.Cancel()
+// settlementFor — что делать с резервами заказа в новом статусе. Резервы меняются в одной
+// транзакции с заказом, поэтому запрос, проигравший по версии, их не трогает.
+func settlementFor(status models.OrderStatus) repository.Settlement {
+	switch status {
+	case models.StatusInProgress:
+		// Заказ взят в работу — резерв больше не истекает
+		return repository.SettleConfirm
+	case models.StatusCompleted:
+		return repository.SettleCommit
+	case models.StatusCancelled:
+		return repository.SettleRelease
+	}
+	return repository.SettleNone
+}
 
-	err = s.repo.Update(order)
+// ExpireReservations отменяет заказы с истёкшим резервом. Резерв освобождается вместе с отменой
+// заказа и проверкой его версии: если заказ параллельно взяли в работу, товар остаётся за ним.
+func (s *orderService) ExpireReservations(now time.Time) (int, error) {
+	orderIDs, err := s.inventory.FindExpired(now)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	event := events.NewOrderCancelledEvent(order)
-	s.eventPublisher.Publish(event)
+	cancelled := 0
+	for _, orderID := range orderIDs {
+		order, err := s.repo.FindByID(orderID)
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			// Резерв без заказа: сервис остановился между резервом и сохранением заказа
+			if err := s.inventory.Release(orderID, now); err != nil {
+				return cancelled, err
+			}
+			continue
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		if order.Status != models.StatusCreated {
+			continue
+		}
 
-	return order, nil
+		if err := order.Cancel(true); err != nil {
+			return cancelled, err
+		}
+		if err := s.repo.Update(order, repository.SettleRelease, events.NewOrderCancelledEvent(order)); errors.Is(err, repository.ErrOrderVersionConflict) {
+			// Заказ изменили параллельно, его статус и резерв решает тот запрос
+			continue
+		} else if err != nil {
+			return cancelled, err
+		}
+		cancelled++
+	}
+	return cancelled, nil
 }
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"order-service/internal/dto"
+	"order-service/internal/repository"
+	"order-service/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const testReservationTTL = 30 * time.Minute
+
+type existingUsers struct{}
+
+func (existingUsers) UserExists(uuid.UUID, string) (bool, error) {
+	return true, nil
+}
+
+func newTestOrderService(t *testing.T, onHand int) (OrderService, repository.InventoryRepository) {
+	t.Helper()
+
+	products := repository.NewInMemoryProductRepository()
+	price, err := models.ParseMoney("10.00", models.DefaultCurrency)
+	if err != nil {
+		t.Fatalf("ParseMoney: %v", err)
+	}
+	if err := products.Create(models.NewProduct("W1", "Widget", price, true)); err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+
+	inventory := repository.NewInMemoryInventoryRepository()
+	if _, err := inventory.SetStock("W1", onHand, time.Now()); err != nil {
+		t.Fatalf("SetStock: %v", err)
+	}
+
+	orders := repository.NewInMemoryOrderRepository(repository.NewInMemoryOutboxRepository(), inventory)
+	return NewOrderService(orders, existingUsers{}, NewLocalCatalogClient(products), inventory, testReservationTTL), inventory
+}
+
+func available(t *testing.T, inventory repository.InventoryRepository) int {
+	t.Helper()
+
+	level, err := inventory.GetStock("W1")
+	if err != nil {
+		t.Fatalf("GetStock: %v", err)
+	}
+	return level.Available
+}
+
+// Покупатель не может взять свой заказ в работу и тем самым снять срок с резерва:
+// заказ отменяется по истечении RESERVATION_TTL, товар возвращается
+func TestUserInProgressDoesNotOutliveReservationTTL(t *testing.T) {
+	orders, inventory := newTestOrderService(t, 5)
+	userID := uuid.New()
+
+	order, err := orders.CreateOrder(userID, &dto.CreateOrderRequest{Items: []dto.OrderItemRequest{{SKU: "W1", Quantity: 3}}}, "token")
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if got := available(t, inventory); got != 2 {
+		t.Fatalf("available after order = %d, want 2", got)
+	}
+
+	_, err = orders.UpdateOrderStatus(order.ID, userID, string(models.StatusInProgress), false, nil)
+	var transitionErr *models.InvalidTransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("user moved the order to in_progress: err = %v", err)
+	}
+
+	cancelled, err := orders.ExpireReservations(order.CreatedAt.Add(testReservationTTL))
+	if err != nil {
+		t.Fatalf("ExpireReservations: %v", err)
+	}
+	if cancelled != 1 {
+		t.Fatalf("cancelled = %d, want 1", cancelled)
+	}
+
+	stored, err := orders.GetOrder(order.ID, userID, false)
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if stored.Status != models.StatusCancelled {
+		t.Errorf("status = %s, want cancelled", stored.Status)
+	}
+	if got := available(t, inventory); got != 5 {
+		t.Errorf("available after expiry = %d, want 5", got)
+	}
+}
+
+// Подтверждённый администратором заказ держит резерв и после RESERVATION_TTL
+func TestAdminInProgressKeepsReservation(t *testing.T) {
+	orders, inventory := newTestOrderService(t, 5)
+	userID := uuid.New()
+
+	order, err := orders.CreateOrder(userID, &dto.CreateOrderRequest{Items: []dto.OrderItemRequest{{SKU: "W1", Quantity: 3}}}, "token")
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if _, err := orders.UpdateOrderStatus(order.ID, uuid.New(), string(models.StatusInProgress), true, nil); err != nil {
+		t.Fatalf("admin UpdateOrderStatus: %v", err)
+	}
+
+	cancelled, err := orders.ExpireReservations(order.CreatedAt.Add(2 * testReservationTTL))
+	if err != nil {
+		t.Fatalf("ExpireReservations: %v", err)
+	}
+	if cancelled != 0 {
+		t.Errorf("cancelled = %d, want 0", cancelled)
+	}
+	if got := available(t, inventory); got != 2 {
+		t.Errorf("available = %d, want 2", got)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"log"
+	"order-service/internal/events"
+	"order-service/internal/repository"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	outboxBatchSize = 100
+	// Предел паузы между повторами, когда брокер недоступен
+	outboxMaxBackoff = time.Minute
+)
+
+// OutboxRelay публикует события из outbox в порядке записи и отмечает опубликованные.
+// Если событие опубликовать не удалось, оно повторяется с растущей паузой, а следующие
+// ждут его, чтобы получатели не увидели события заказа не по порядку. Доставка — не реже
+// одного раза: если сервис остановится между публикацией и отметкой, событие уйдёт
+// повторно, поэтому получатели отбрасывают повторы по id события.
+type OutboxRelay struct {
+	outbox    repository.OutboxRepository
+	publisher events.EventPublisher
+	interval  time.Duration
+
+	dispatched atomic.Int64
+	failures   atomic.Int64
+	// Задержка публикации последнего события от его записи, в наносекундах
+	lastDelay atomic.Int64
+}
+
+// OutboxRelayStats — счётчики relay с запуска сервиса
+type OutboxRelayStats struct {
+	Dispatched int64
+	Failures   int64
+	LastDelay  time.Duration
+}
+
+func NewOutboxRelay(outbox repository.OutboxRepository, publisher events.EventPublisher, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		outbox:    outbox,
+		publisher: publisher,
+		interval:  interval,
+	}
+}
+
+// Run проверяет outbox раз в interval, пока не отменён ctx
+func (r *OutboxRelay) Run(ctx context.Context) {
+	delay := r.interval
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if err := r.DispatchPending(ctx); err != nil {
+			delay = min(delay*2, outboxMaxBackoff)
+			log.Printf("Outbox relay failed, retrying in %s: %v", delay, err)
+		} else {
+			delay = r.interval
+		}
+		timer.Reset(delay)
+	}
+}
+
+// DispatchPending публикует все накопившиеся события и останавливается на первой ошибке
+func (r *OutboxRelay) DispatchPending(ctx context.Context) error {
+	for ctx.Err() == nil {
+		pending, err := r.outbox.Pending(outboxBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, stored := range pending {
+			if err := r.publisher.Publish(stored.Event); err != nil {
+				r.failures.Add(1)
+				if markErr := r.outbox.MarkFailed(stored.Event.ID, err.Error()); markErr != nil {
+					log.Printf("Failed to record outbox attempt for event %s: %v", stored.Event.ID, markErr)
+				}
+				return err
+			}
+
+			now := time.Now()
+			if err := r.outbox.MarkDispatched(stored.Event.ID, now); err != nil {
+				return err
+			}
+			r.dispatched.Add(1)
+			r.lastDelay.Store(int64(now.Sub(stored.Event.Timestamp)))
+		}
+
+		if len(pending) < outboxBatchSize {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+func (r *OutboxRelay) Stats() OutboxRelayStats {
+	return OutboxRelayStats{
+		Dispatched: r.dispatched.Load(),
+		Failures:   r.failures.Load(),
+		LastDelay:  time.Duration(r.lastDelay.Load()),
+	}
+}
+
+// RunOutboxCleanup раз в час удаляет события, опубликованные больше retention назад
+func RunOutboxCleanup(ctx context.Context, outbox repository.OutboxRepository, retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := outbox.DeleteDispatched(now.Add(-retention)); err != nil {
+				log.Printf("Outbox cleanup failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"order-service/internal/dto"
+	"order-service/internal/repository"
+	"order-service/models"
+	"strings"
+	"time"
+)
+
+type ProductService interface {
+	CreateProduct(req *dto.CreateProductRequest) (*models.Product, error)
+	// GetProduct без includeInactive не находит скрытые товары
+	GetProduct(sku string, includeInactive bool) (*models.Product, error)
+	ListProducts(includeInactive bool, page, limit int) ([]*models.Product, int, error)
+	UpdateProduct(sku string, req *dto.UpdateProductRequest) (*models.Product, error)
+	DeleteProduct(sku string) error
+	GetStock(sku string) (*models.StockLevel, error)
+	SetStock(sku string, onHand int) (*models.StockLevel, error)
+}
+
+type productService struct {
+	repo      repository.ProductRepository
+	inventory repository.InventoryRepository
+}
+
+func NewProductService(repo repository.ProductRepository, inventory repository.InventoryRepository) ProductService {
+	return &productService{repo: repo, inventory: inventory}
+}
+
+func (s *productService) CreateProduct(req *dto.CreateProductRequest) (*models.Product, error) {
+	currency := req.Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	price, err := models.ParseMoney(string(req.Price), currency)
+	if err != nil {
+		return nil, err
+	}
+
+	active := req.Active == nil || *req.Active
+	product := models.NewProduct(req.SKU, strings.TrimSpace(req.Name), price, active)
+	if err := s.repo.Create(product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (s *productService) GetProduct(sku string, includeInactive bool) (*models.Product, error) {
+	product, err := s.repo.FindBySKU(sku)
+	if err != nil {
+		return nil, err
+	}
+	if !product.Active && !includeInactive {
+		return nil, repository.ErrProductNotFound
+	}
+	return product, nil
+}
+
+func (s *productService) ListProducts(includeInactive bool, page, limit int) ([]*models.Product, int, error) {
+	return s.repo.FindAll(includeInactive, page, limit)
+}
+
+func (s *productService) UpdateProduct(sku string, req *dto.UpdateProductRequest) (*models.Product, error) {
+	product, err := s.repo.FindBySKU(sku)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		product.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Price != nil {
+		currency := product.Currency
+		if req.Currency != nil {
+			currency = *req.Currency
+		}
+		price, err := models.ParseMoney(string(*req.Price), currency)
+		if err != nil {
+			return nil, err
+		}
+		if price.Amount <= 0 {
+			return nil, errors.New("price must be greater than 0")
+		}
+		product.Price = price
+		product.Currency = currency
+	}
+	if req.Active != nil {
+		product.Active = *req.Active
+	}
+	product.UpdatedAt = time.Now()
+
+	if err := s.repo.Update(product); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// DeleteProduct удаляет товар из каталога; в заказах остаются его название и цена на момент заказа
+func (s *productService) DeleteProduct(sku string) error {
+	return s.repo.Delete(sku)
+}
+
+func (s *productService) GetStock(sku string) (*models.StockLevel, error) {
+	if _, err := s.repo.FindBySKU(sku); err != nil {
+		return nil, err
+	}
+	return s.inventory.GetStock(sku)
+}
+
+// SetStock задаёт остаток на складе, например после инвентаризации или поставки
+func (s *productService) SetStock(sku string, onHand int) (*models.StockLevel, error) {
+	if _, err := s.repo.FindBySKU(sku); err != nil {
+		return nil, err
+	}
+	return s.inventory.SetStock(sku, onHand, time.Now())
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunReservationExpiry с заданным интервалом отменяет заказы, резерв которых истёк
+func RunReservationExpiry(ctx context.Context, orders OrderService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			cancelled, err := orders.ExpireReservations(now)
+			if err != nil {
+				log.Printf("Reservation expiry failed: %v", err)
+			}
+			if cancelled > 0 {
+				log.Printf("Cancelled %d orders with expired stock reservations", cancelled)
+			}
+		}
+	}
+}
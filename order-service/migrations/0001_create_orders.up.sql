@@ -0,0 +1,21 @@
+-- IF NOT EXISTS: базы, созданные до появления миграций, принимаются как есть
+CREATE TABLE IF NOT EXISTS orders (
+    id           VARCHAR(36)      PRIMARY KEY,
+    user_id      VARCHAR(36)      NOT NULL,
+    status       VARCHAR(20)      NOT NULL,
+    total_amount DOUBLE PRECISION NOT NULL,
+    created_at   TIMESTAMP        NOT NULL,
+    updated_at   TIMESTAMP        NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS orders_user_created_idx ON orders (user_id, created_at);
+CREATE INDEX IF NOT EXISTS orders_user_total_idx ON orders (user_id, total_amount);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    order_id     VARCHAR(36)      NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    position     INTEGER          NOT NULL,
+    product_name VARCHAR(255)     NOT NULL,
+    quantity     INTEGER          NOT NULL,
+    price        DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (order_id, position)
+);
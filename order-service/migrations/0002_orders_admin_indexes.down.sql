@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS orders_status_created_idx;
+DROP INDEX IF EXISTS orders_created_idx;
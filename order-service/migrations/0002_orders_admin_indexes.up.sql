@@ -0,0 +1,3 @@
+-- Выборка заказов всех пользователей (GET /api/v1/admin/orders)
+CREATE INDEX IF NOT EXISTS orders_created_idx ON orders (created_at);
+CREATE INDEX IF NOT EXISTS orders_status_created_idx ON orders (status, created_at);
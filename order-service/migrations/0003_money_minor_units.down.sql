@@ -0,0 +1,12 @@
+-- Точность сумм в валютах с экспонентой, отличной от 2, при откате теряется
+ALTER TABLE orders ADD COLUMN total_amount DOUBLE PRECISION NOT NULL DEFAULT 0;
+UPDATE orders SET total_amount = total_amount_minor / 100.0;
+
+ALTER TABLE order_items ADD COLUMN price DOUBLE PRECISION NOT NULL DEFAULT 0;
+UPDATE order_items SET price = price_minor / 100.0;
+
+DROP INDEX IF EXISTS orders_user_total_idx;
+ALTER TABLE orders DROP COLUMN total_amount_minor;
+ALTER TABLE orders DROP COLUMN currency;
+ALTER TABLE order_items DROP COLUMN price_minor;
+CREATE INDEX IF NOT EXISTS orders_user_total_idx ON orders (user_id, total_amount);
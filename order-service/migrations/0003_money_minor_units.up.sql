@@ -0,0 +1,13 @@
+-- Суммы хранятся целыми минимальными единицами валюты вместо DOUBLE PRECISION.
+-- Существующие заказы считаются рублёвыми (2 знака после запятой).
+ALTER TABLE orders ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT 'RUB';
+ALTER TABLE orders ADD COLUMN total_amount_minor BIGINT NOT NULL DEFAULT 0;
+UPDATE orders SET total_amount_minor = CAST(ROUND(total_amount * 100) AS BIGINT);
+
+ALTER TABLE order_items ADD COLUMN price_minor BIGINT NOT NULL DEFAULT 0;
+UPDATE order_items SET price_minor = CAST(ROUND(price * 100) AS BIGINT);
+
+DROP INDEX IF EXISTS orders_user_total_idx;
+ALTER TABLE orders DROP COLUMN total_amount;
+ALTER TABLE order_items DROP COLUMN price;
+CREATE INDEX IF NOT EXISTS orders_user_total_idx ON orders (user_id, total_amount_minor);
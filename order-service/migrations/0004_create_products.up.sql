@@ -0,0 +1,13 @@
+-- Каталог товаров: цены заказов берутся отсюда
+CREATE TABLE products (
+    sku         VARCHAR(64)  PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    price_minor BIGINT       NOT NULL,
+    currency    VARCHAR(3)   NOT NULL,
+    active      BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMP    NOT NULL,
+    updated_at  TIMESTAMP    NOT NULL
+);
+
+-- У позиций старых заказов SKU нет
+ALTER TABLE order_items ADD COLUMN sku VARCHAR(64) NOT NULL DEFAULT '';
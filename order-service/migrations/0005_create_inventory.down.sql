@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS stock_reservations;
+DROP TABLE IF EXISTS stock_levels;
@@ -0,0 +1,21 @@
+-- Остатки товаров; reserved — часть остатка, удержанная под заказы
+CREATE TABLE stock_levels (
+    sku        VARCHAR(64) PRIMARY KEY,
+    on_hand    INTEGER     NOT NULL CHECK (on_hand >= 0),
+    reserved   INTEGER     NOT NULL DEFAULT 0 CHECK (reserved >= 0 AND reserved <= on_hand),
+    updated_at TIMESTAMP   NOT NULL
+);
+
+-- Резервы под позиции заказов; expires_at задан, пока заказ не взят в работу
+CREATE TABLE stock_reservations (
+    order_id   VARCHAR(36) NOT NULL,
+    sku        VARCHAR(64) NOT NULL,
+    quantity   INTEGER     NOT NULL CHECK (quantity > 0),
+    status     VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP   NOT NULL,
+    updated_at TIMESTAMP   NOT NULL,
+    PRIMARY KEY (order_id, sku)
+);
+
+CREATE INDEX stock_reservations_expiry_idx ON stock_reservations (status, expires_at);
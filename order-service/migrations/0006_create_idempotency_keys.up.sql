@@ -0,0 +1,15 @@
+-- Запросы с Idempotency-Key и их ответы для повтора
+CREATE TABLE idempotency_keys (
+    user_id          VARCHAR(36)  NOT NULL,
+    idempotency_key  VARCHAR(255) NOT NULL,
+    fingerprint      VARCHAR(64)  NOT NULL,
+    completed        BOOLEAN      NOT NULL DEFAULT FALSE,
+    response_status  INTEGER      NOT NULL DEFAULT 0,
+    response_headers TEXT         NOT NULL DEFAULT '',
+    response_body    TEXT         NOT NULL DEFAULT '',
+    created_at       TIMESTAMP    NOT NULL,
+    expires_at       TIMESTAMP    NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE INDEX idempotency_keys_expires_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,2 @@
+-- Версия для оптимистичных блокировок: существующие заказы начинают с 1
+ALTER TABLE orders ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
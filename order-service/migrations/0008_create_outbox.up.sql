@@ -0,0 +1,15 @@
+-- События заказов, записанные в одной транзакции с заказом; публикует их relay
+CREATE TABLE outbox_events (
+    id            VARCHAR(36) PRIMARY KEY,
+    order_id      VARCHAR(36) NOT NULL,
+    order_version BIGINT      NOT NULL,
+    event_type    VARCHAR(50) NOT NULL,
+    payload       TEXT        NOT NULL,
+    created_at    TIMESTAMP   NOT NULL,
+    attempts      INTEGER     NOT NULL DEFAULT 0,
+    last_error    TEXT        NOT NULL DEFAULT '',
+    dispatched_at TIMESTAMP
+);
+
+CREATE INDEX outbox_events_pending_idx ON outbox_events (created_at, order_id, order_version) WHERE dispatched_at IS NULL;
+CREATE INDEX outbox_events_dispatched_idx ON outbox_events (dispatched_at);
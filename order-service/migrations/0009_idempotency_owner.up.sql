@@ -0,0 +1,3 @@
+-- Владелец записи — попытка обработки, которая её создала или заняла: прерванную запись
+-- занимает только один повтор, а ответ сохраняет только её владелец. У существующих записей владельца нет.
+ALTER TABLE idempotency_keys ADD COLUMN owner VARCHAR(36) NOT NULL DEFAULT '';
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockLevel — остаток товара на складе; Reserved — часть остатка, удержанная под заказы
+type StockLevel struct {
+	SKU       string    `json:"sku"`
+	OnHand    int       `json:"onHand"`
+	Reserved  int       `json:"reserved"`
+	Available int       `json:"available"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ReservationStatus string
+
+const (
+	// Товар удержан под заказ
+	ReservationActive ReservationStatus = "active"
+	// Заказ выполнен, товар списан с остатка
+	ReservationCommitted ReservationStatus = "committed"
+	// Заказ отменён или резерв истёк, товар вернулся в доступный остаток
+	ReservationReleased ReservationStatus = "released"
+)
+
+// Reservation — удержание товара под позицию заказа. ExpiresAt задан, пока заказ
+// не взят в работу: неподтверждённый резерв истекает, а заказ отменяется.
+type Reservation struct {
+	OrderID   uuid.UUID         `json:"orderId"`
+	SKU       string            `json:"sku"`
+	Quantity  int               `json:"quantity"`
+	Status    ReservationStatus `json:"status"`
+	ExpiresAt *time.Time        `json:"expiresAt,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// NewReservations — резервы под все позиции заказа
+func NewReservations(order *Order, expiresAt time.Time) []*Reservation {
+	reservations := make([]*Reservation, 0, len(order.Items))
+	for _, item := range order.Items {
+		reservations = append(reservations, &Reservation{
+			OrderID:   order.ID,
+			SKU:       item.SKU,
+			Quantity:  item.Quantity,
+			Status:    ReservationActive,
+			ExpiresAt: &expiresAt,
+			CreatedAt: order.CreatedAt,
+			UpdatedAt: order.CreatedAt,
+		})
+	}
+	return reservations
+}
+
+type OutOfStockItem struct {
+	SKU       string `json:"sku"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// OutOfStockError — доступного остатка не хватает; резервы не созданы ни по одной позиции
+type OutOfStockError struct {
+	Items []OutOfStockItem
+}
+
+func (e *OutOfStockError) Error() string {
+	items := make([]string, len(e.Items))
+	for i, item := range e.Items {
+		items[i] = fmt.Sprintf("%s (requested %d, available %d)", item.SKU, item.Requested, item.Available)
+	}
+	return "not enough stock: " + strings.Join(items, ", ")
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Валюта заказа, если клиент её не указал
+const DefaultCurrency = "RUB"
+
+// Число знаков после запятой (ISO 4217) для поддерживаемых валют
+var currencyExponents = map[string]int{
+	"RUB": 2, "USD": 2, "EUR": 2, "GBP": 2, "CHF": 2, "CNY": 2,
+	"KZT": 2, "BYN": 2, "UAH": 2, "TRY": 2, "INR": 2, "AED": 2,
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0,
+	"KWD": 3, "BHD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+}
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrAmountOverflow   = errors.New("amount is too large")
+)
+
+// Money — сумма в минимальных единицах валюты (копейки, центы), без ошибок округления float64
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func CurrencyExponent(currency string) (int, error) {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrUnknownCurrency, currency)
+	}
+	return exponent, nil
+}
+
+// ParseMoney разбирает десятичную строку ("12.5", "-3", "0.125") в минимальные единицы.
+// Лишние знаки округляются до экспоненты валюты половиной от нуля: 0.125 USD → 0.13.
+func ParseMoney(value, currency string) (Money, error) {
+	exponent, err := CurrencyExponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "-"), "+")
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" && fraction == "" || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("%w %q", ErrInvalidAmount, value)
+	}
+
+	// Дробная часть дополняется нулями до экспоненты; следующая цифра решает округление
+	fraction += strings.Repeat("0", exponent+1)
+	roundUp := fraction[exponent] >= '5'
+	digits := strings.TrimLeft(whole+fraction[:exponent], "0")
+	if digits == "" {
+		digits = "0"
+	}
+
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, ErrAmountOverflow
+	}
+	if roundUp {
+		if amount == math.MaxInt64 {
+			return Money{}, ErrAmountOverflow
+		}
+		amount++
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+func (m Money) Mul(quantity int) (Money, error) {
+	if quantity != 0 && (m.Amount > math.MaxInt64/int64(quantity) || m.Amount < math.MinInt64/int64(quantity)) {
+		return Money{}, ErrAmountOverflow
+	}
+	return Money{Amount: m.Amount * int64(quantity), Currency: m.Currency}, nil
+}
+
+// String — десятичная запись с числом знаков по валюте: "1500.00", "300" (JPY)
+func (m Money) String() string {
+	exponent := currencyExponents[m.Currency]
+	sign, abs := "", uint64(m.Amount)
+	if m.Amount < 0 {
+		// -(Amount+1)+1 не переполняется на MinInt64
+		sign, abs = "-", uint64(-(m.Amount+1))+1
+	}
+	digits := strconv.FormatUint(abs, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+// MarshalJSON — сумма строкой, чтобы клиенты не теряли точность; валюта передаётся рядом
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     int64
+		wantErr  error
+	}{
+		{"12.5", "USD", 1250, nil},
+		{"12.50", "USD", 1250, nil},
+		{"12", "USD", 1200, nil},
+		{"0", "USD", 0, nil},
+		{"1.", "USD", 100, nil},
+		{".5", "USD", 50, nil},
+		{"+3", "USD", 300, nil},
+		{" 7.25 ", "RUB", 725, nil},
+		{"000123.40", "EUR", 12340, nil},
+
+		// Лишние знаки округляются половиной от нуля
+		{"0.125", "USD", 13, nil},
+		{"0.124", "USD", 12, nil},
+		{"0.1251", "USD", 13, nil},
+		{"0.995", "USD", 100, nil},
+		{"-0.125", "USD", -13, nil},
+		{"-0.124", "USD", -12, nil},
+		{"-0.001", "USD", 0, nil},
+
+		// Число знаков — по валюте
+		{"300", "JPY", 300, nil},
+		{"1.5", "JPY", 2, nil},
+		{"1.4", "JPY", 1, nil},
+		{"1.2345", "KWD", 1235, nil},
+		{"1.234", "KWD", 1234, nil},
+		{"-3", "KWD", -3000, nil},
+
+		// Граница int64
+		{"92233720368547758.07", "USD", math.MaxInt64, nil},
+		{"92233720368547758.08", "USD", 0, ErrAmountOverflow},
+		{"92233720368547758.075", "USD", 0, ErrAmountOverflow},
+		{"-92233720368547758.07", "USD", -math.MaxInt64, nil},
+
+		// Некорректный ввод
+		{"", "USD", 0, ErrInvalidAmount},
+		{"-", "USD", 0, ErrInvalidAmount},
+		{".", "USD", 0, ErrInvalidAmount},
+		{"abc", "USD", 0, ErrInvalidAmount},
+		{"1,5", "USD", 0, ErrInvalidAmount},
+		{"1.2.3", "USD", 0, ErrInvalidAmount},
+		{"--1", "USD", 0, ErrInvalidAmount},
+		{"1e3", "USD", 0, ErrInvalidAmount},
+		{"12 USD", "USD", 0, ErrInvalidAmount},
+		{"10", "XXX", 0, ErrUnknownCurrency},
+		{"10", "usd", 0, ErrUnknownCurrency},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value, tt.currency)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseMoney(%q, %s) error = %v, want %v", tt.value, tt.currency, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q, %s): %v", tt.value, tt.currency, err)
+			continue
+		}
+		if got.Amount != tt.want || got.Currency != tt.currency {
+			t.Errorf("ParseMoney(%q, %s) = %d %s, want %d %s", tt.value, tt.currency, got.Amount, got.Currency, tt.want, tt.currency)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{Amount: 150000, Currency: "RUB"}, "1500.00"},
+		{Money{Amount: 5, Currency: "USD"}, "0.05"},
+		{Money{Amount: -5, Currency: "USD"}, "-0.05"},
+		{Money{Amount: 300, Currency: "JPY"}, "300"},
+		{Money{Amount: 1235, Currency: "KWD"}, "1.235"},
+		{Money{Amount: math.MinInt64, Currency: "USD"}, "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("%d %s: String() = %q, want %q", tt.money.Amount, tt.money.Currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	usd := func(amount int64) Money { return Money{Amount: amount, Currency: "USD"} }
+
+	if sum, err := usd(150).Add(usd(275)); err != nil || sum != usd(425) {
+		t.Errorf("Add = %v, %v; want 425 USD", sum, err)
+	}
+	if _, err := usd(1).Add(Money{Amount: 1, Currency: "EUR"}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add of different currencies: err = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if _, err := usd(math.MaxInt64).Add(usd(1)); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Add overflow: err = %v, want %v", err, ErrAmountOverflow)
+	}
+	if _, err := usd(math.MinInt64).Add(usd(-1)); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Add underflow: err = %v, want %v", err, ErrAmountOverflow)
+	}
+
+	if product, err := usd(1250).Mul(3); err != nil || product != usd(3750) {
+		t.Errorf("Mul = %v, %v; want 3750 USD", product, err)
+	}
+	if _, err := usd(math.MaxInt64 / 2).Mul(3); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Mul overflow: err = %v, want %v", err, ErrAmountOverflow)
+	}
+}
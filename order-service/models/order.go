@@ -15,10 +15,12 @@ const (
 	StatusCancelled  OrderStatus = "cancelled"
 )
 
+// OrderItem хранит название и цену товара на момент заказа
 type OrderItem struct {
-	ProductName string  `json:"productName"`
-	Quantity    int     `json:"quantity"`
-	Price       float64 `json:"price"`
+	SKU         string `json:"sku,omitempty"`
+	ProductName string `json:"productName"`
+	Quantity    int    `json:"quantity"`
+	Price       Money  `json:"price"`
 }
 
 type Order struct {
@@ -26,15 +28,25 @@ type Order struct {
 	UserID      uuid.UUID   `json:"userId"`
 	Items       []OrderItem `json:"items"`
 	Status      OrderStatus `json:"status"`
-	TotalAmount float64     `json:"totalAmount"`
+	TotalAmount Money       `json:"totalAmount"`
+	Currency    string      `json:"currency"`
 	CreatedAt   time.Time   `json:"createdAt"`
 	UpdatedAt   time.Time   `json:"updatedAt"`
+	// Version растёт с каждым сохранением: по нему проверяются параллельные изменения (ETag)
+	Version int64 `json:"version"`
 }
 
-func NewOrder(userID uuid.UUID, items []OrderItem) *Order {
-	totalAmount := 0.0
+// NewOrder считает сумму в минимальных единицах; все позиции должны быть в одной валюте
+func NewOrder(userID uuid.UUID, currency string, items []OrderItem) (*Order, error) {
+	total := Money{Currency: currency}
 	for _, item := range items {
-		totalAmount += item.Price * float64(item.Quantity)
+		line, err := item.Price.Mul(item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		if total, err = total.Add(line); err != nil {
+			return nil, err
+		}
 	}
 
 	return &Order{
@@ -42,18 +54,24 @@ func NewOrder(userID uuid.UUID, items []OrderItem) *Order {
 		UserID:      userID,
 		Items:       items,
 		Status:      StatusCreated,
-		TotalAmount: totalAmount,
+		TotalAmount: total,
+		Currency:    currency,
+		Version:     1,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
-	}
+	}, nil
 }
 
-func (o *Order) UpdateStatus(status OrderStatus) {
+// UpdateStatus меняет статус по таблице переходов (order_transitions.go)
+func (o *Order) UpdateStatus(status OrderStatus, isAdmin bool) error {
+	if err := o.checkTransition(status, isAdmin); err != nil {
+		return err
+	}
 	o.Status = status
 	o.UpdatedAt = time.Now()
+	return nil
 }
 
-func (o *Order) Cancel() {
-	o.Status = StatusCancelled
-	o.UpdatedAt = time.Now()
+func (o *Order) Cancel(isAdmin bool) error {
+	return o.UpdateStatus(StatusCancelled, isAdmin)
 }
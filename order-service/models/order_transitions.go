@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+type transition struct {
+	to OrderStatus
+	// Переход доступен только администратору
+	adminOnly bool
+}
+
+// orderTransitions — разрешённые переходы статуса; completed и cancelled конечные.
+// Взятие в работу подтверждает резерв и снимает с него срок, поэтому доступно только
+// администратору: иначе покупатель мог бы удерживать товар бессрочно.
+var orderTransitions = map[OrderStatus][]transition{
+	StatusCreated: {
+		{to: StatusInProgress, adminOnly: true},
+		{to: StatusCancelled},
+	},
+	StatusInProgress: {
+		{to: StatusCompleted, adminOnly: true},
+		{to: StatusCancelled},
+	},
+	StatusCompleted: {},
+	StatusCancelled: {},
+}
+
+// InvalidTransitionError — переход не разрешён из текущего статуса или для роли пользователя
+type InvalidTransitionError struct {
+	From    OrderStatus
+	To      OrderStatus
+	Allowed []OrderStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	allowed := "none"
+	if len(e.Allowed) > 0 {
+		names := make([]string, len(e.Allowed))
+		for i, status := range e.Allowed {
+			names[i] = string(status)
+		}
+		allowed = strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("cannot change order status from %s to %s, allowed: %s", e.From, e.To, allowed)
+}
+
+// AllowedTransitions возвращает статусы, в которые пользователь может перевести заказ
+func AllowedTransitions(from OrderStatus, isAdmin bool) []OrderStatus {
+	allowed := []OrderStatus{}
+	for _, t := range orderTransitions[from] {
+		if !t.adminOnly || isAdmin {
+			allowed = append(allowed, t.to)
+		}
+	}
+	return allowed
+}
+
+func (o *Order) AllowedTransitions(isAdmin bool) []OrderStatus {
+	return AllowedTransitions(o.Status, isAdmin)
+}
+
+func (o *Order) checkTransition(to OrderStatus, isAdmin bool) error {
+	allowed := o.AllowedTransitions(isAdmin)
+	for _, status := range allowed {
+		if status == to {
+			return nil
+		}
+	}
+	return &InvalidTransitionError{From: o.Status, To: to, Allowed: allowed}
+}
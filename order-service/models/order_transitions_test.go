@@ -0,0 +1,116 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var allStatuses = []OrderStatus{StatusCreated, StatusInProgress, StatusCompleted, StatusCancelled}
+
+// Полная таблица: для каждой пары статусов — доступен ли переход администратору и владельцу
+func TestOrderTransitions(t *testing.T) {
+	type rule struct{ admin, user bool }
+	rules := map[OrderStatus]map[OrderStatus]rule{
+		StatusCreated: {
+			StatusInProgress: {admin: true},
+			StatusCancelled:  {admin: true, user: true},
+		},
+		StatusInProgress: {
+			StatusCompleted: {admin: true},
+			StatusCancelled: {admin: true, user: true},
+		},
+		// completed и cancelled конечные
+		StatusCompleted: {},
+		StatusCancelled: {},
+	}
+
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			for _, isAdmin := range []bool{true, false} {
+				want := rules[from][to].user
+				role := "user"
+				if isAdmin {
+					want = rules[from][to].admin
+					role = "admin"
+				}
+
+				t.Run(string(from)+"->"+string(to)+"/"+role, func(t *testing.T) {
+					order := &Order{Status: from}
+					err := order.UpdateStatus(to, isAdmin)
+
+					if want {
+						if err != nil {
+							t.Fatalf("UpdateStatus: %v", err)
+						}
+						if order.Status != to {
+							t.Errorf("status = %s, want %s", order.Status, to)
+						}
+						return
+					}
+
+					var transitionErr *InvalidTransitionError
+					if !errors.As(err, &transitionErr) {
+						t.Fatalf("err = %v, want *InvalidTransitionError", err)
+					}
+					if order.Status != from {
+						t.Errorf("status changed to %s on a rejected transition", order.Status)
+					}
+					if transitionErr.From != from || transitionErr.To != to {
+						t.Errorf("error is about %s -> %s, want %s -> %s", transitionErr.From, transitionErr.To, from, to)
+					}
+					if want := AllowedTransitions(from, isAdmin); !reflect.DeepEqual(transitionErr.Allowed, want) {
+						t.Errorf("Allowed = %v, want %v", transitionErr.Allowed, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestAllowedTransitions(t *testing.T) {
+	tests := []struct {
+		from    OrderStatus
+		isAdmin bool
+		want    []OrderStatus
+	}{
+		{StatusCreated, true, []OrderStatus{StatusInProgress, StatusCancelled}},
+		{StatusCreated, false, []OrderStatus{StatusCancelled}},
+		{StatusInProgress, true, []OrderStatus{StatusCompleted, StatusCancelled}},
+		{StatusInProgress, false, []OrderStatus{StatusCancelled}},
+		{StatusCompleted, true, []OrderStatus{}},
+		{StatusCompleted, false, []OrderStatus{}},
+		{StatusCancelled, true, []OrderStatus{}},
+		{StatusCancelled, false, []OrderStatus{}},
+		// Неизвестный статус никуда не ведёт
+		{OrderStatus("archived"), true, []OrderStatus{}},
+	}
+
+	for _, tt := range tests {
+		if got := AllowedTransitions(tt.from, tt.isAdmin); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AllowedTransitions(%s, admin=%t) = %v, want %v", tt.from, tt.isAdmin, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidTransitionErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *InvalidTransitionError
+		want string
+	}{
+		{
+			err:  &InvalidTransitionError{From: StatusCreated, To: StatusCompleted, Allowed: []OrderStatus{StatusInProgress, StatusCancelled}},
+			want: "cannot change order status from created to completed, allowed: in_progress, cancelled",
+		},
+		{
+			err:  &InvalidTransitionError{From: StatusCompleted, To: StatusCancelled, Allowed: []OrderStatus{}},
+			want: "cannot change order status from completed to cancelled, allowed: none",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
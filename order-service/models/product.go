@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Product — товар каталога. Цена задаётся администратором, заказ берёт её отсюда, а не от клиента.
+type Product struct {
+	SKU      string `json:"sku"`
+	Name     string `json:"name"`
+	Price    Money  `json:"price"`
+	Currency string `json:"currency"`
+	// Неактивный товар скрыт из каталога и не может быть заказан
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func NewProduct(sku, name string, price Money, active bool) *Product {
+	return &Product{
+		SKU:       sku,
+		Name:      name,
+		Price:     price,
+		Currency:  price.Currency,
+		Active:    active,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
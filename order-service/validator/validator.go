@@ -2,30 +2,102 @@ package validator
 
 import (
 	"errors"
+	"fmt"
 	"order-service/internal/dto"
+	"order-service/models"
+	"regexp"
+	"strings"
 )
 
+// SKU — латинские буквы, цифры, точка, дефис и подчёркивание
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
 func ValidateCreateOrderRequest(req *dto.CreateOrderRequest) error {
 	if len(req.Items) == 0 {
 		return errors.New("order must contain at least one item")
 	}
 
-	for i, item := range req.Items {
-		if item.ProductName == "" {
-			return errors.New("product name is required for all items")
+	if req.Currency != "" {
+		if _, err := models.CurrencyExponent(req.Currency); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]bool, len(req.Items))
+	for _, item := range req.Items {
+		if item.SKU == "" {
+			return errors.New("sku is required for all items")
+		}
+		if seen[item.SKU] {
+			return fmt.Errorf("duplicate sku %q, use quantity instead", item.SKU)
 		}
+		seen[item.SKU] = true
 		if item.Quantity <= 0 {
 			return errors.New("quantity must be greater than 0")
 		}
-		if item.Price < 0 {
-			return errors.New("price cannot be negative")
-		}
-		if item.Price == 0 {
-			return errors.New("price must be greater than 0")
+	}
+
+	return nil
+}
+
+func ValidateSKU(sku string) error {
+	if !skuPattern.MatchString(sku) {
+		return errors.New("invalid sku: up to 64 letters, digits, '.', '-' or '_'")
+	}
+	return nil
+}
+
+func ValidateCreateProductRequest(req *dto.CreateProductRequest) error {
+	if err := ValidateSKU(req.SKU); err != nil {
+		return err
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		return errors.New("name is required")
+	}
+	currency := req.Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	return validatePrice(req.Price, currency)
+}
+
+func ValidateUpdateProductRequest(req *dto.UpdateProductRequest) error {
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		return errors.New("name cannot be empty")
+	}
+	if req.Currency != nil {
+		// Прежняя сумма в другой валюте не имеет смысла
+		if req.Price == nil {
+			return errors.New("price is required when changing currency")
 		}
-		_ = i
+		return validatePrice(*req.Price, *req.Currency)
+	}
+	if req.Price != nil {
+		// Точность проверяется по валюте товара в сервисе, здесь — только формат и знак
+		return validatePrice(*req.Price, models.DefaultCurrency)
 	}
+	return nil
+}
 
+func ValidateSetStockRequest(req *dto.SetStockRequest) error {
+	if req.OnHand == nil {
+		return errors.New("onHand is required")
+	}
+	if *req.OnHand < 0 {
+		return errors.New("onHand cannot be negative")
+	}
+	return nil
+}
+
+func validatePrice(price dto.Amount, currency string) error {
+	parsed, err := models.ParseMoney(string(price), currency)
+	if err != nil {
+		return fmt.Errorf("invalid price: %w", err)
+	}
+	// Цена, округлённая до минимальной единицы валюты, тоже должна быть положительной
+	if parsed.Amount <= 0 {
+		return errors.New("price must be greater than 0")
+	}
 	return nil
 }
 
@@ -43,3 +115,18 @@ func ValidateOrderStatus(status string) error {
 
 	return nil
 }
+
+func ValidateOrderSort(sortBy string) error {
+	validSorts := map[string]bool{
+		"createdAt_desc":   true,
+		"createdAt_asc":    true,
+		"totalAmount_desc": true,
+		"totalAmount_asc":  true,
+	}
+
+	if !validSorts[sortBy] {
+		return errors.New("invalid sort, expected createdAt_desc, createdAt_asc, totalAmount_desc or totalAmount_asc")
+	}
+
+	return nil
+}
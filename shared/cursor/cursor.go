@@ -0,0 +1,85 @@
+// Package cursor — непрозрачные курсоры пагинации, подписанные HMAC-SHA256,
+// чтобы клиент не мог подменить позицию или параметры выборки
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+)
+
+var ErrInvalid = errors.New("invalid cursor")
+
+type Codec struct {
+	secret []byte
+}
+
+// NewCodec без секрета создаёт случайный ключ: курсоры перестанут действовать
+// после перезапуска и не подойдут другим репликам
+func NewCodec(secret string) *Codec {
+	if secret == "" {
+		log.Printf("CURSOR_SECRET is not set, using an ephemeral cursor key")
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+		return &Codec{secret: key}
+	}
+	return &Codec{secret: []byte(secret)}
+}
+
+// Encode сериализует позицию в JSON и подписывает: base64url(payload).base64url(mac)
+func (c *Codec) Encode(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.sign(encoded)), nil
+}
+
+func (c *Codec) Decode(s string, v interface{}) error {
+	encoded, signature, found := strings.Cut(s, ".")
+	if !found {
+		return ErrInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, c.sign(encoded)) {
+		return ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalid
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return ErrInvalid
+	}
+	return nil
+}
+
+func (c *Codec) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// Fingerprint — отпечаток параметров выборки без параметров пагинации.
+// Курсор хранит его, чтобы не применяться к выборке с другими фильтрами или сортировкой.
+func Fingerprint(query url.Values, paginationParams ...string) string {
+	filtered := url.Values{}
+	for key, values := range query {
+		filtered[key] = values
+	}
+	for _, param := range paginationParams {
+		filtered.Del(param)
+	}
+	sum := sha256.Sum256([]byte(filtered.Encode()))
+	return hex.EncodeToString(sum[:8])
+}
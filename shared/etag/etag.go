@@ -0,0 +1,52 @@
+// Package etag — ETag по номеру версии ресурса и разбор If-Match для оптимистичных блокировок
+package etag
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Format возвращает сильный ETag версии: "3"
+func Format(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// Condition — версии из If-Match. nil — условия нет (заголовок пустой или "*"),
+// пустой непустой срез — условие, которому не подходит ни одна версия.
+type Condition []int64
+
+// ParseIfMatch разбирает If-Match. Слабые (W/) и не наши ETag не совпадают ни с чем:
+// If-Match требует строгого сравнения.
+func ParseIfMatch(header string) Condition {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	versions := Condition{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// Matches — версия удовлетворяет условию; без условия подходит любая
+func (c Condition) Matches(version int64) bool {
+	if c == nil {
+		return true
+	}
+	for _, v := range c {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -1,3 +1,8 @@
 module github.com/ChrolloLucii/control-system/shared
 
 go 1.25.3
+
+require (
+	github.com/golang-jwt/jwt/v5 v5.3.0
+	golang.org/x/sync v0.22.0
+)
@@ -0,0 +1,31 @@
+// Package identity — внутреннее подтверждение личности пользователя, которое шлюз
+// подписывает своим ключом после проверки JWT и передаёт сервисам в заголовке Header
+package identity
+
+import (
+	"time"
+
+	"github.com/ChrolloLucii/control-system/shared/jwks"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	Header   = "X-Internal-Identity"
+	Issuer   = "gateway"
+	Audience = "internal"
+	// Подтверждение нужно только на время одного запроса
+	TTL = time.Minute
+)
+
+// Parse проверяет подпись шлюза, издателя, аудиторию и срок действия и заполняет claims.
+// Поля claims те же, что и в токене пользователя: userId, email, roles.
+func Parse(assertion string, gatewayKeys *jwks.Client, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(assertion, claims, gatewayKeys.Keyfunc,
+		jwt.WithValidMethods([]string{jwks.AlgorithmES256}),
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(5*time.Second),
+	)
+	return err
+}
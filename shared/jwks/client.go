@@ -0,0 +1,190 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// Алгоритмы, которые допускаются при разборе токенов
+var ValidMethods = []string{AlgorithmRS256, AlgorithmES256}
+
+// Не чаще этого интервала клиент идёт за ключами из-за неизвестного kid или устаревшего набора,
+// чтобы поток токенов с выдуманными kid не превратился в поток запросов к user-service
+const minRefreshInterval = 10 * time.Second
+
+// Сколько Keyfunc без контекста запроса ждёт загрузки ключей
+const keyLookupTimeout = 5 * time.Second
+
+type cachedKey struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// Client кэширует JWKS и перечитывает его по истечении refreshInterval
+// или когда встречает токен, подписанный ещё неизвестным ключом (после ротации).
+// Загрузка идёт без блокировки кэша, а одновременные запросы ключей ждут одну общую загрузку.
+type Client struct {
+	url             string
+	httpClient      *http.Client
+	refreshInterval time.Duration
+	refreshes       singleflight.Group
+
+	mu          sync.RWMutex
+	keys        map[string]cachedKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func NewClient(url string, refreshInterval time.Duration) *Client {
+	return &Client{
+		url:             url,
+		httpClient:      &http.Client{Timeout: 5 * time.Second},
+		refreshInterval: refreshInterval,
+		keys:            make(map[string]cachedKey),
+	}
+}
+
+// Keyfunc подходит для jwt.Parse, когда контекста запроса нет; ожидание ключей ограничено keyLookupTimeout
+func (c *Client) Keyfunc(token *jwt.Token) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyLookupTimeout)
+	defer cancel()
+
+	return c.KeyfuncContext(ctx)(token)
+}
+
+// KeyfuncContext ищет ключ по kid из заголовка и проверяет, что алгоритм совпадает с ключом.
+// Если за ключом нужно сходить, ожидание прерывается вместе с ctx.
+func (c *Client) KeyfuncContext(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token has no kid")
+		}
+
+		key, alg, err := c.Key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != alg {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), kid)
+		}
+		return key, nil
+	}
+}
+
+// Key возвращает публичный ключ и его алгоритм
+func (c *Client) Key(ctx context.Context, kid string) (crypto.PublicKey, string, error) {
+	cached, found, stale := c.lookup(kid)
+	if stale || !found {
+		if err := c.refresh(ctx, false); err != nil {
+			// Пока user-service недоступен, работаем с ранее загруженными ключами
+			log.Printf("Failed to refresh JWKS from %s: %v", c.url, err)
+		}
+		cached, found, _ = c.lookup(kid)
+	}
+
+	if !found {
+		return nil, "", fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+	return cached.key, cached.alg, nil
+}
+
+// Refresh загружает ключи немедленно, например чтобы проверить доступность user-service при старте
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.refresh(ctx, true)
+}
+
+func (c *Client) lookup(kid string) (cachedKey, bool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, found := c.keys[kid]
+	return cached, found, time.Since(c.fetchedAt) > c.refreshInterval
+}
+
+// refresh присоединяется к идущей загрузке или начинает новую. Без force загрузка начинается
+// не чаще minRefreshInterval, иначе refresh сразу возвращается и ключи остаются прежними.
+func (c *Client) refresh(ctx context.Context, force bool) error {
+	// Загрузку разделяют несколько запросов, поэтому отмена одного из них её не прерывает:
+	// её ограничивает таймаут httpClient, а ожидание каждого — его собственный ctx
+	result := c.refreshes.DoChan("jwks", func() (interface{}, error) {
+		c.mu.Lock()
+		if !force && time.Since(c.attemptedAt) <= minRefreshInterval {
+			c.mu.Unlock()
+			return nil, nil
+		}
+		c.attemptedAt = time.Now()
+		c.mu.Unlock()
+
+		keys, err := c.fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		c.keys = keys
+		c.fetchedAt = time.Now()
+		c.mu.Unlock()
+		return nil, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case res := <-result:
+		return res.Err
+	}
+}
+
+func (c *Client) fetch(ctx context.Context) (map[string]cachedKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var set Set
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]cachedKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			log.Printf("Skipping JWKS key: %v", err)
+			continue
+		}
+		// alg в JWK необязателен, но алгоритм однозначно следует из типа ключа
+		alg := AlgorithmRS256
+		if jwk.Kty == "EC" {
+			alg = AlgorithmES256
+		}
+		if jwk.Alg != "" && jwk.Alg != alg {
+			log.Printf("Skipping JWKS key %s: unsupported algorithm %s", jwk.Kid, jwk.Alg)
+			continue
+		}
+		keys[jwk.Kid] = cachedKey{alg: alg, key: key}
+	}
+	return keys, nil
+}
@@ -0,0 +1,118 @@
+// Package jwks описывает публичные ключи в формате JWK (RFC 7517)
+// и клиент, который загружает их с эндпоинта /.well-known/jwks.json
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Поддерживаемые алгоритмы подписи
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+)
+
+type Key struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// NewKey описывает публичный ключ RSA (RS256) или P-256 (ES256)
+func NewKey(kid string, publicKey crypto.PublicKey) (Key, error) {
+	encode := base64.RawURLEncoding.EncodeToString
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return Key{
+			Kid: kid,
+			Kty: "RSA",
+			Alg: AlgorithmRS256,
+			Use: "sig",
+			N:   encode(key.N.Bytes()),
+			E:   encode(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return Key{}, errors.New("only P-256 curve is supported")
+		}
+		point, err := key.ECDH()
+		if err != nil {
+			return Key{}, err
+		}
+		// Несжатая точка: 0x04 || X || Y, по 32 байта на координату
+		raw := point.Bytes()
+		return Key{
+			Kid: kid,
+			Kty: "EC",
+			Alg: AlgorithmES256,
+			Use: "sig",
+			Crv: "P-256",
+			X:   encode(raw[1:33]),
+			Y:   encode(raw[33:]),
+		}, nil
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T", publicKey)
+	}
+}
+
+// PublicKey восстанавливает ключ для проверки подписи
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: invalid modulus: %w", k.Kid, err)
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: invalid exponent: %w", k.Kid, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %s: invalid exponent", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("key %s: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: invalid x: %w", k.Kid, err)
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: invalid y: %w", k.Kid, err)
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("key %s: invalid point", k.Kid)
+		}
+		// ParseUncompressedPublicKey проверяет, что точка лежит на кривой
+		raw := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), raw)
+	default:
+		return nil, fmt.Errorf("key %s: unsupported key type %q", k.Kid, k.Kty)
+	}
+}
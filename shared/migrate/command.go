@@ -0,0 +1,83 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const usage = "usage: migrate up | down [steps] | status"
+
+// Command выполняет подкоманду migrate сервиса: up, down [steps] или status
+func Command(ctx context.Context, m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errors.New(usage)
+		}
+		applied, err := m.Up(ctx)
+		for _, migration := range applied {
+			fmt.Fprintf(out, "applied  %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "schema is up to date")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) == 2 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed < 1 {
+				return fmt.Errorf("invalid steps %q: %s", args[1], usage)
+			}
+			steps = parsed
+		} else if len(args) > 2 {
+			return errors.New(usage)
+		}
+		reverted, err := m.Down(ctx, steps)
+		for _, migration := range reverted {
+			fmt.Fprintf(out, "reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Fprintln(out, "no migrations to revert")
+		}
+		return err
+
+	case "status":
+		if len(args) != 1 {
+			return errors.New(usage)
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			switch {
+			case status.Unknown:
+				state = "unknown"
+			case status.Modified:
+				state = "modified"
+			case status.AppliedAt != nil:
+				state = "applied"
+			}
+			appliedAt := ""
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d_%-30s %-8s %s\n", status.Version, status.Name, state, appliedAt)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q: %s", args[0], usage)
+	}
+}
@@ -0,0 +1,347 @@
+// Package migrate — версионированные миграции схемы, встроенные в бинарник сервиса.
+// Файлы называются NNNN_name.up.sql и NNNN_name.down.sql; применённые версии
+// вместе с контрольной суммой up-скрипта хранятся в таблице schema_migrations.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// Ключ pg_advisory_lock, общий для всех реплик сервиса
+const advisoryLockKey = 7_245_031_884
+
+var (
+	ErrChecksumMismatch = errors.New("applied migration has been modified")
+	ErrUnknownMigration = errors.New("database has a migration that is not known to this build")
+	ErrNoDownMigration  = errors.New("migration has no down script")
+)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Status — состояние миграции для команды status
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+	// Скрипт изменён после применения
+	Modified bool
+	// Версия есть в базе, но не в сборке
+	Unknown bool
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New загружает миграции из корня fsys (обычно embed.FS пакета migrations сервиса)
+func New(db *sql.DB, dialect Dialect, fsys fs.FS) (*Migrator, error) {
+	if dialect != SQLite && dialect != Postgres {
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		base := path.Base(file)
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("%s: expected .up.sql or .down.sql suffix", file)
+		}
+
+		prefix, name, found := strings.Cut(strings.TrimSuffix(base, "."+direction+".sql"), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if !found || err != nil || version <= 0 {
+			return nil, fmt.Errorf("%s: expected NNNN_name.%s.sql", file, direction)
+		}
+
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", migration.Version, migration.Name)
+		}
+		sum := sha256.Sum256([]byte(migration.Up))
+		migration.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up применяет все ещё не применённые миграции по порядку
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(done); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, exists := done[migration.Version]; exists {
+				continue
+			}
+			err := m.step(ctx, conn, migration.Up,
+				`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)`,
+				migration.Version, migration.Name, migration.Checksum, time.Now().UTC(),
+			)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	if err != nil && m.dialect == SQLite {
+		// В SQLite прогон откатывается целиком
+		applied = nil
+	}
+	return applied, err
+}
+
+// Down откатывает steps последних применённых миграций
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(done); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, exists := done[migration.Version]; !exists {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, ErrNoDownMigration)
+			}
+			err := m.step(ctx, conn, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, migration.Version,
+			)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	if err != nil && m.dialect == SQLite {
+		reverted = nil
+	}
+	return reverted, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if applied, exists := done[migration.Version]; exists {
+				appliedAt := applied.appliedAt
+				status.AppliedAt = &appliedAt
+				status.Modified = applied.checksum != migration.Checksum
+				delete(done, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for version, applied := range done {
+			appliedAt := applied.appliedAt
+			statuses = append(statuses, Status{Version: version, Name: applied.name, AppliedAt: &appliedAt, Unknown: true})
+		}
+		sort.Slice(statuses, func(i, j int) bool {
+			return statuses[i].Version < statuses[j].Version
+		})
+		return nil
+	})
+	return statuses, err
+}
+
+// verify не даёт мигрировать базу, если применённые скрипты изменились
+// или база новее сборки
+func (m *Migrator) verify(done map[int64]appliedMigration) error {
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+	for version, applied := range done {
+		migration, exists := known[version]
+		if !exists {
+			return fmt.Errorf("%w: %04d_%s", ErrUnknownMigration, version, applied.name)
+		}
+		if applied.checksum != migration.Checksum {
+			return fmt.Errorf("%w: %04d_%s", ErrChecksumMismatch, version, migration.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var version int64
+		var applied appliedMigration
+		if err := rows.Scan(&version, &applied.name, &applied.checksum, &applied.appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = applied
+	}
+	return done, rows.Err()
+}
+
+// locked выполняет fn под блокировкой, чтобы реплики не применяли миграции одновременно.
+// PostgreSQL: advisory lock, каждая миграция — в своей транзакции.
+// SQLite: advisory locks нет, поэтому весь прогон идёт в одной транзакции BEGIN IMMEDIATE,
+// которая сразу берёт блокировку записи; вторая реплика ждёт её (busy_timeout).
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect == Postgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			// Блокировка держится на соединении: при ошибке соединение закрывается вместе с ней
+			if _, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockKey); unlockErr != nil && err == nil {
+				err = unlockErr
+			}
+		}()
+	} else {
+		if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err != nil {
+				conn.ExecContext(context.Background(), `ROLLBACK`)
+				return
+			}
+			_, err = conn.ExecContext(ctx, `COMMIT`)
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT       PRIMARY KEY,
+		name       VARCHAR(255) NOT NULL,
+		checksum   VARCHAR(64)  NOT NULL,
+		applied_at TIMESTAMP    NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// step выполняет скрипт миграции и запись в schema_migrations атомарно
+func (m *Migrator) step(ctx context.Context, conn *sql.Conn, script, record string, args ...interface{}) error {
+	if m.dialect == SQLite {
+		// Уже внутри транзакции из locked
+		if _, err := conn.ExecContext(ctx, script); err != nil {
+			return err
+		}
+		_, err := conn.ExecContext(ctx, record, args...)
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
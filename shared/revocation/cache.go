@@ -0,0 +1,146 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Сколько user-service держит запрос, если изменений нет
+const longPollWait = 30 * time.Second
+
+// Пауза перед повтором, если user-service недоступен
+const retryDelay = 5 * time.Second
+
+type userCutoff struct {
+	revokedBefore time.Time
+	expiresAt     time.Time
+}
+
+// Cache — локальная копия списка отзыва. Run держит long-poll запрос к user-service,
+// поэтому отзыв доходит до сервисов почти сразу, а проверка токена не требует сетевых вызовов.
+type Cache struct {
+	url        string
+	httpClient *http.Client
+
+	mu      sync.RWMutex
+	epoch   string
+	version int64
+	tokens  map[string]time.Time
+	users   map[string]userCutoff
+}
+
+func NewCache(url string) *Cache {
+	return &Cache{
+		url:        url,
+		httpClient: &http.Client{Timeout: longPollWait + 10*time.Second},
+		tokens:     make(map[string]time.Time),
+		users:      make(map[string]userCutoff),
+	}
+}
+
+// IsRevoked проверяет токен по jti и по времени выдачи для отзыва всех токенов пользователя
+func (c *Cache) IsRevoked(jti, userID string, issuedAt time.Time) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, revoked := c.tokens[jti]; jti != "" && revoked {
+		return true
+	}
+	if cutoff, exists := c.users[userID]; exists {
+		return IsRevokedBefore(issuedAt, cutoff.revokedBefore)
+	}
+	return false
+}
+
+// Run синхронизирует список до отмены ctx
+func (c *Cache) Run(ctx context.Context) {
+	for {
+		err := c.sync(ctx, longPollWait)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// Пока user-service недоступен, действует последний полученный список
+			log.Printf("Failed to sync token revocations from %s: %v", c.url, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+		}
+	}
+}
+
+// Sync загружает изменения без ожидания, например при старте сервиса
+func (c *Cache) Sync(ctx context.Context) error {
+	return c.sync(ctx, 0)
+}
+
+func (c *Cache) sync(ctx context.Context, wait time.Duration) error {
+	c.mu.RLock()
+	query := url.Values{
+		"epoch": {c.epoch},
+		"since": {strconv.FormatInt(c.version, 10)},
+		"wait":  {wait.String()},
+	}
+	c.mu.RUnlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var changes Changes
+	if err := json.NewDecoder(resp.Body).Decode(&changes); err != nil {
+		return fmt.Errorf("invalid revocation list: %w", err)
+	}
+
+	c.apply(changes)
+	return nil
+}
+
+func (c *Cache) apply(changes Changes) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if changes.Full {
+		c.tokens = make(map[string]time.Time)
+		c.users = make(map[string]userCutoff)
+	}
+	for _, token := range changes.Tokens {
+		c.tokens[token.JTI] = token.ExpiresAt
+	}
+	for _, user := range changes.Users {
+		c.users[user.UserID] = userCutoff{revokedBefore: user.RevokedBefore, expiresAt: user.ExpiresAt}
+	}
+	c.epoch = changes.Epoch
+	c.version = changes.Version
+
+	// Записи об уже истёкших токенах больше ни на что не влияют
+	now := time.Now()
+	for jti, expiresAt := range c.tokens {
+		if now.After(expiresAt) {
+			delete(c.tokens, jti)
+		}
+	}
+	for userID, cutoff := range c.users {
+		if now.After(cutoff.expiresAt) {
+			delete(c.users, userID)
+		}
+	}
+}
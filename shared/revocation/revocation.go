@@ -0,0 +1,36 @@
+// Package revocation — список отозванных access-токенов, который ведёт user-service,
+// и его локальная копия для сервисов, проверяющих токены
+package revocation
+
+import "time"
+
+// Changes — ответ эндпоинта /internal/revocations.
+// Записи возвращаются начиная с версии since; если эпоха клиента устарела (список в user-service создан заново),
+// возвращается весь список и Full = true.
+type Changes struct {
+	Epoch   string         `json:"epoch"`
+	Version int64          `json:"version"`
+	Full    bool           `json:"full"`
+	Tokens  []RevokedToken `json:"tokens"`
+	Users   []RevokedUser  `json:"users"`
+}
+
+// RevokedToken — отозванный токен; запись нужна только до истечения самого токена
+type RevokedToken struct {
+	JTI       string    `json:"jti"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// RevokedUser — все токены пользователя, выданные раньше RevokedBefore, недействительны.
+// Запись нужна, пока не истекут все такие токены (ExpiresAt).
+type RevokedUser struct {
+	UserID        string    `json:"userId"`
+	RevokedBefore time.Time `json:"revokedBefore"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+}
+
+// IsRevokedBefore сообщает, выдан ли токен до отзыва. JWT хранит iat с точностью до секунды,
+// поэтому токены, выданные в ту же секунду, что и отзыв, тоже считаются отозванными.
+func IsRevokedBefore(issuedAt, revokedBefore time.Time) bool {
+	return !issuedAt.After(revokedBefore.Truncate(time.Second))
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"time"
 	"user-service/internal/handlers"
 	"user-service/internal/middleware"
 	"user-service/internal/repository"
 	"user-service/internal/service"
+	"user-service/migrations"
 
+	"github.com/ChrolloLucii/control-system/shared/cursor"
+	"github.com/ChrolloLucii/control-system/shared/jwks"
+	"github.com/ChrolloLucii/control-system/shared/migrate"
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
+	_ "modernc.org/sqlite"
 )
 
 func main() {
@@ -19,10 +27,37 @@ func main() {
 		log.Println("No .env file found, using system variables")
 
 	}
-	userRepo := repository.NewInMemoryUserRepository()
-	jwtService := service.NewJWTService()
-	userService := service.NewUserService(userRepo, jwtService)
-	userHandler := handlers.NewUserHandler(userService)
+	// user-service migrate up|down [steps]|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate.Command(context.Background(), newMigrator(openUserDB()), os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+	// Токены подписываются асимметричными ключами; другие сервисы проверяют их по JWKS
+	// Access-токен короткоживущий, продлевается через refresh-токен
+	tokenTTL := getEnvDuration("JWT_EXPIRES_IN", 15*time.Minute)
+	keyManager, err := service.NewKeyManager(
+		getEnv("JWT_KEYS_DIR", "keys"),
+		getEnv("JWT_ALGORITHM", "ES256"),
+		getEnvDuration("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		// Прежний ключ публикуется, пока не истекут подписанные им токены
+		getEnvDuration("JWT_KEY_OVERLAP", tokenTTL),
+	)
+	if err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+	go keyManager.Run(context.Background())
+
+	repos := newRepositories()
+	revocations := service.NewRevocationList(repos.revocations, tokenTTL)
+	jwtService := service.NewJWTService(keyManager, revocations, tokenTTL)
+	userService := service.NewUserService(repos.users, repos.refreshTokens, jwtService, revocations, getEnvDuration("REFRESH_TOKEN_EXPIRES_IN", 30*24*time.Hour))
+	userHandler := handlers.NewUserHandler(userService, jwtService, revocations,
+		// Ключ шлюза для проверки X-Internal-Identity
+		jwks.NewClient(getEnv("GATEWAY_JWKS_URL", "http://localhost:8080/.well-known/gateway-jwks.json"), 5*time.Minute),
+		// Подпись курсоров пагинации; у всех реплик должна быть одна
+		cursor.NewCodec(getEnv("CURSOR_SECRET", "")))
 
 	r := chi.NewRouter()
 
@@ -31,20 +66,95 @@ func main() {
 	r.Use(middleware.RequestIDMiddleware)
 	r.Use(middleware.CORSMiddleware)
 
-	userHandler.RegisterRoutes(r, jwtService)
+	userHandler.RegisterRoutes(r)
 
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"ok","service":"user-service"}`))
 	})
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3001"
-	}
+	port := getEnv("PORT", "3001")
 
 	log.Printf("User Service starting up... on port %s", port)
 	if err := http.ListenAndServe(":"+port, r); err != nil {
 		log.Fatalf("Error starting User Service: %v", err)
 	}
 }
+
+type repositories struct {
+	users         repository.UserRepository
+	refreshTokens repository.RefreshTokenRepository
+	revocations   repository.RevocationRepository
+}
+
+// newRepositories выбирает хранилища по USER_STORE: sqlite (по умолчанию) или memory
+func newRepositories() repositories {
+	switch store := getEnv("USER_STORE", "sqlite"); store {
+	case "memory":
+		return repositories{
+			users:         repository.NewInMemoryUserRepository(),
+			refreshTokens: repository.NewInMemoryRefreshTokenRepository(),
+			revocations:   repository.NewInMemoryRevocationRepository(),
+		}
+	case "sqlite":
+		db := openUserDB()
+		// Миграции защищены блокировкой, поэтому реплики могут запускать их одновременно
+		if getEnv("AUTO_MIGRATE", "true") == "true" {
+			applied, err := newMigrator(db).Up(context.Background())
+			if err != nil {
+				log.Fatalf("Failed to migrate user database: %v", err)
+			}
+			for _, migration := range applied {
+				log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+			}
+		}
+		return repositories{
+			users:         repository.NewSQLUserRepository(db),
+			refreshTokens: repository.NewSQLRefreshTokenRepository(db),
+			revocations:   repository.NewSQLRevocationRepository(db),
+		}
+	default:
+		log.Fatalf("Unknown USER_STORE %q, expected sqlite or memory", store)
+		return repositories{}
+	}
+}
+
+func openUserDB() *sql.DB {
+	if store := getEnv("USER_STORE", "sqlite"); store != "sqlite" {
+		log.Fatalf("USER_STORE %q has no database", store)
+	}
+	db, err := sql.Open("sqlite", getEnv("USER_DB_DSN", "file:users.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"))
+	if err != nil {
+		log.Fatalf("Failed to open user database: %v", err)
+	}
+	return db
+}
+
+func newMigrator(db *sql.DB) *migrate.Migrator {
+	migrator, err := migrate.New(db, migrate.SQLite, migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	return migrator
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
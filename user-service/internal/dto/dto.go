@@ -12,8 +12,20 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string      `json:"token"`
-	User  interface{} `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refreshToken"`
+	ExpiresIn    int         `json:"expiresIn"`
+	User         interface{} `json:"user"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"`
 }
 
 type UpdateProfileRequest struct {
@@ -37,9 +49,13 @@ type PaginatedResponse struct {
 	Meta    MetaDTO     `json:"meta"`
 }
 
+// MetaDTO — page/totalPages/totalItems в режиме page/limit, nextCursor/prevCursor — в режиме курсоров.
+// totalPages и totalItems отдаются всегда, как до появления курсоров: в режиме курсоров они нулевые.
 type MetaDTO struct {
-	Page       int `json:"page"`
-	Limit      int `json:"limit"`
-	TotalPages int `json:"totalPages"`
-	TotalItems int `json:"totalItems"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	TotalPages int    `json:"totalPages"`
+	TotalItems int    `json:"totalItems"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
 }
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"user-service/internal/dto"
+	"user-service/internal/repository"
+	"user-service/models"
+
+	"github.com/ChrolloLucii/control-system/shared/cursor"
+	"github.com/google/uuid"
+)
+
+// Параметры пагинации не входят в отпечаток выборки
+var paginationParams = []string{"cursor", "limit", "page"}
+
+// userCursor — содержимое курсора: позиция в выборке и отпечаток её параметров
+type userCursor struct {
+	Query     string    `json:"q"`
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+// isLegacyPagination — клиент не перешёл на курсоры: без параметра cursor список отдаётся
+// постранично, как раньше. Первая страница в режиме курсоров — ?cursor= с пустым значением.
+func isLegacyPagination(query url.Values) bool {
+	return !query.Has("cursor")
+}
+
+func (h *UserHandler) decodeCursor(query url.Values) (*repository.UserSeek, error) {
+	value := query.Get("cursor")
+	if value == "" {
+		return nil, nil
+	}
+
+	var c userCursor
+	if err := h.cursors.Decode(value, &c); err != nil {
+		return nil, err
+	}
+	if c.Query != cursor.Fingerprint(query, paginationParams...) {
+		return nil, errors.New("cursor does not match the query parameters")
+	}
+	return &repository.UserSeek{CreatedAt: c.CreatedAt, ID: c.ID, Backward: c.Backward}, nil
+}
+
+func (h *UserHandler) encodeCursor(query url.Values, seek *repository.UserSeek) (string, error) {
+	return h.cursors.Encode(userCursor{
+		Query:     cursor.Fingerprint(query, paginationParams...),
+		CreatedAt: seek.CreatedAt.UTC(),
+		ID:        seek.ID,
+		Backward:  seek.Backward,
+	})
+}
+
+// respondWithCursorPage отдаёт страницу с курсорами соседних страниц в meta и в заголовке Link
+func (h *UserHandler) respondWithCursorPage(w http.ResponseWriter, r *http.Request, query url.Values, users []*models.User, seek *repository.UserSeek, hasMore bool, limit int) {
+	meta := dto.MetaDTO{Limit: limit}
+
+	if len(users) > 0 {
+		backward := seek != nil && seek.Backward
+		var links []string
+		// Вперёд есть страницы, если они остались после этой или мы пришли с них назад
+		if hasMore && !backward || backward {
+			next, err := h.encodeCursor(query, repository.SeekFor(users[len(users)-1], false))
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
+				return
+			}
+			meta.NextCursor = next
+			links = append(links, pageLink(r, query, next, limit, "next"))
+		}
+		if hasMore && backward || seek != nil && !backward {
+			prev, err := h.encodeCursor(query, repository.SeekFor(users[0], true))
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
+				return
+			}
+			meta.PrevCursor = prev
+			links = append(links, pageLink(r, query, prev, limit, "prev"))
+		}
+		if len(links) > 0 {
+			w.Header().Set("Link", strings.Join(links, ", "))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(dto.PaginatedResponse{
+		Success: true,
+		Data:    users,
+		Meta:    meta,
+	})
+}
+
+func pageLink(r *http.Request, query url.Values, cursorValue string, limit int, rel string) string {
+	params := url.Values{}
+	for key, values := range query {
+		params[key] = values
+	}
+	params.Del("page")
+	params.Set("cursor", cursorValue)
+	params.Set("limit", strconv.Itoa(limit))
+	return "<" + r.URL.Path + "?" + params.Encode() + `>; rel="` + rel + `"`
+}
+
+func respondWithPage(w http.ResponseWriter, users []*models.User, page, limit, total int) {
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+
+	response := dto.PaginatedResponse{
+		Success: true,
+		Data:    users,
+		Meta: dto.MetaDTO{
+			Page:       page,
+			Limit:      limit,
+			TotalPages: totalPages,
+			TotalItems: total,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
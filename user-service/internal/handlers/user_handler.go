@@ -2,24 +2,40 @@ package handlers
 
 import (
 	"encoding/json"
-	"math"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 	"user-service/internal/dto"
 	"user-service/internal/middleware"
+	"user-service/internal/repository"
 	"user-service/internal/service"
+	"user-service/models"
 	"user-service/validator"
 
+	"github.com/ChrolloLucii/control-system/shared/cursor"
+	"github.com/ChrolloLucii/control-system/shared/etag"
+	"github.com/ChrolloLucii/control-system/shared/jwks"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 type UserHandler struct {
 	userService service.UserService
+	jwtService  service.JWTService
+	revocations *service.RevocationList
+	gatewayKeys *jwks.Client
+	cursors     *cursor.Codec
 }
 
-func NewUserHandler(userService service.UserService) *UserHandler {
+func NewUserHandler(userService service.UserService, jwtService service.JWTService, revocations *service.RevocationList, gatewayKeys *jwks.Client, cursors *cursor.Codec) *UserHandler {
 	return &UserHandler{
 		userService: userService,
+		jwtService:  jwtService,
+		revocations: revocations,
+		gatewayKeys: gatewayKeys,
+		cursors:     cursors,
 	}
 }
 
@@ -41,7 +57,7 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithSuccess(w, http.StatusCreated, user)
+	respondWithUser(w, http.StatusCreated, user)
 }
 
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -56,20 +72,80 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, token, err := h.userService.Login(&req)
+	user, tokens, err := h.userService.Login(&req)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "LOGIN_FAILED", err.Error())
 		return
 	}
 
 	response := dto.LoginResponse{
-		Token: token,
-		User:  user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int(tokens.ExpiresIn.Seconds()),
+		User:         user,
 	}
 
 	respondWithSuccess(w, http.StatusOK, response)
 }
 
+func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "invalid request body")
+		return
+	}
+
+	if err := validator.ValidateRefreshTokenRequest(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	tokens, err := h.userService.RefreshTokens(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRefreshTokenReused):
+			respondWithError(w, http.StatusUnauthorized, "REFRESH_TOKEN_REUSED", err.Error())
+		case errors.Is(err, service.ErrInvalidRefreshToken):
+			respondWithError(w, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN", err.Error())
+		default:
+			respondWithError(w, http.StatusInternalServerError, "REFRESH_FAILED", err.Error())
+		}
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, dto.TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int(tokens.ExpiresIn.Seconds()),
+	})
+}
+
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_REQUEST", "invalid request body")
+		return
+	}
+
+	if err := validator.ValidateRefreshTokenRequest(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	// Access-токен необязателен: если он передан и ещё действует, отзывается и он
+	var accessToken *service.Claims
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		accessToken, _ = h.jwtService.ValidateToken(bearer)
+	}
+
+	if err := h.userService.Logout(req.RefreshToken, accessToken); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "LOGOUT_FAILED", err.Error())
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, map[string]string{"message": "logged out"})
+}
+
 func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(middleware.UserContextKey).(*service.Claims)
 	if !ok {
@@ -83,7 +159,7 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithSuccess(w, http.StatusOK, user)
+	respondWithUser(w, http.StatusOK, user)
 }
 
 func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
@@ -104,60 +180,120 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userService.UpdateProfile(claims.UserID, &req)
+	user, err := h.userService.UpdateProfile(claims.UserID, &req, etag.ParseIfMatch(r.Header.Get("If-Match")))
+	if errors.Is(err, repository.ErrUserVersionConflict) {
+		respondWithError(w, http.StatusPreconditionFailed, "PRECONDITION_FAILED", err.Error())
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "UPDATE_FAILED", err.Error())
 		return
 	}
 
-	respondWithSuccess(w, http.StatusOK, user)
+	respondWithUser(w, http.StatusOK, user)
 }
 
 func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
+	query := r.URL.Query()
 
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
 	if limit < 1 {
 		limit = 10
 	}
 
-	role := r.URL.Query().Get("role")
+	role := query.Get("role")
+
+	// Устаревший режим page/limit
+	if isLegacyPagination(query) {
+		page, _ := strconv.Atoi(query.Get("page"))
+		if page < 1 {
+			page = 1
+		}
 
-	users, total, err := h.userService.GetUsers(page, limit, role)
+		users, total, err := h.userService.GetUsers(page, limit, role)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
+			return
+		}
+
+		respondWithPage(w, users, page, limit, total)
+		return
+	}
+
+	seek, err := h.decodeCursor(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_CURSOR", err.Error())
+		return
+	}
+
+	users, hasMore, err := h.userService.GetUsersPage(role, seek, limit)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "FETCH_FAILED", err.Error())
 		return
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	h.respondWithCursorPage(w, r, query, users, seek, hasMore, limit)
+}
 
-	response := dto.PaginatedResponse{
-		Success: true,
-		Data:    users,
-		Meta: dto.MetaDTO{
-			Page:       page,
-			Limit:      limit,
-			TotalPages: totalPages,
-			TotalItems: total,
-		},
+// RevokeUserTokens завершает все сессии пользователя (admin)
+func (h *UserHandler) RevokeUserTokens(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_ID", "invalid user id")
+		return
+	}
+
+	if err := h.userService.RevokeUserTokens(userID); err != nil {
+		if errors.Is(err, service.ErrRevocationFailed) {
+			respondWithError(w, http.StatusInternalServerError, "REVOKE_FAILED", err.Error())
+			return
+		}
+		respondWithError(w, http.StatusNotFound, "USER_NOT_FOUND", err.Error())
+		return
+	}
+
+	respondWithSuccess(w, http.StatusOK, map[string]string{"message": "all user tokens revoked"})
+}
+
+// Revocations отдаёт изменения списка отзыва шлюзу и сервисам. Параметры: epoch и since — что клиент уже знает,
+// wait — сколько ждать изменений (не больше минуты). Эндпоинт внутренний и через шлюз не публикуется.
+func (h *UserHandler) Revocations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	since, _ := strconv.ParseInt(query.Get("since"), 10, 64)
+	wait, _ := time.ParseDuration(query.Get("wait"))
+	wait = min(max(wait, 0), time.Minute)
+
+	changes, err := h.revocations.Changes(r.Context(), query.Get("epoch"), since, wait)
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "REVOCATIONS_UNAVAILABLE", err.Error())
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(changes)
 }
 
-func (h *UserHandler) RegisterRoutes(r chi.Router, jwtService service.JWTService) {
+func (h *UserHandler) RegisterRoutes(r chi.Router) {
+
+	// Ключи для проверки токенов шлюзом и другими сервисами
+	r.Get("/.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(h.jwtService.JWKS())
+	})
+
 	r.Route("/api/v1/users", func(r chi.Router) {
 		// публично
 		r.Post("/register", h.Register)
 		r.Post("/login", h.Login)
+		// Refresh-токен сам подтверждает личность, access-токен к этому моменту может истечь
+		r.Post("/token/refresh", h.RefreshToken)
+		r.Post("/logout", h.Logout)
 		//защищено
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.AuthMiddleware(jwtService))
+			r.Use(middleware.AuthMiddleware(h.jwtService, h.gatewayKeys))
 			r.Get("/profile", h.GetProfile)
 			r.Put("/profile", h.UpdateProfile)
 
@@ -165,9 +301,12 @@ func (h *UserHandler) RegisterRoutes(r chi.Router, jwtService service.JWTService
 			r.Group(func(r chi.Router) {
 				r.Use(middleware.AdminMiddleware)
 				r.Get("/", h.GetUsers)
+				r.Post("/{id}/revoke-tokens", h.RevokeUserTokens)
 			})
 		})
 	})
+
+	r.Get("/internal/revocations", h.Revocations)
 }
 
 func respondWithError(w http.ResponseWriter, status int, code, message string) {
@@ -182,6 +321,12 @@ func respondWithError(w http.ResponseWriter, status int, code, message string) {
 	})
 }
 
+// respondWithUser отдаёт профиль с ETag его версии — для If-Match в следующем изменении
+func respondWithUser(w http.ResponseWriter, status int, user *models.User) {
+	w.Header().Set("ETag", etag.Format(user.Version))
+	respondWithSuccess(w, status, user)
+}
+
 func respondWithSuccess(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
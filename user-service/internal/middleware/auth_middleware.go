@@ -2,21 +2,38 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 	"user-service/internal/dto"
 	"user-service/internal/service"
 
 	"encoding/json"
+
+	"github.com/ChrolloLucii/control-system/shared/identity"
+	"github.com/ChrolloLucii/control-system/shared/jwks"
 )
 
 type contextKey string
 
 const UserContextKey contextKey = "user"
 
-func AuthMiddleware(jwtService service.JWTService) func(http.Handler) http.Handler {
+// AuthMiddleware определяет пользователя по подтверждению шлюза (X-Internal-Identity),
+// а для запросов в обход шлюза — по самому токену
+func AuthMiddleware(jwtService service.JWTService, gatewayKeys *jwks.Client) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if assertion := r.Header.Get(identity.Header); assertion != "" {
+				claims := &service.Claims{}
+				if err := identity.Parse(assertion, gatewayKeys, claims); err != nil {
+					respondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "invalid internal identity")
+					return
+				}
+				ctx := context.WithValue(r.Context(), UserContextKey, claims)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
 				respondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authorization header required")
@@ -31,6 +48,10 @@ func AuthMiddleware(jwtService service.JWTService) func(http.Handler) http.Handl
 
 			token := parts[1]
 			claims, err := jwtService.ValidateToken(token)
+			if errors.Is(err, service.ErrTokenRevoked) {
+				respondWithError(w, http.StatusUnauthorized, "TOKEN_REVOKED", "token has been revoked")
+				return
+			}
 			if err != nil {
 				respondWithError(w, http.StatusUnauthorized, "UNAUTHORIZED", "invalid or expired token")
 				return
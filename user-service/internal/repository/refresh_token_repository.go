@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"user-service/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrRefreshTokenUsed = errors.New("refresh token already used")
+
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) error
+	FindByHash(tokenHash string) (*models.RefreshToken, error)
+	// MarkUsed атомарно помечает токен использованным; ErrRefreshTokenUsed — если его уже обменяли
+	MarkUsed(tokenHash string, usedAt time.Time) error
+	RevokeFamily(familyID uuid.UUID, revokedAt time.Time) error
+	RevokeUser(userID uuid.UUID, revokedAt time.Time) error
+}
+
+type InMemoryRefreshTokenRepository struct {
+	tokens    map[string]*models.RefreshToken
+	mu        sync.RWMutex
+	nextSweep time.Time
+}
+
+func NewInMemoryRefreshTokenRepository() *InMemoryRefreshTokenRepository {
+	return &InMemoryRefreshTokenRepository{
+		tokens:    make(map[string]*models.RefreshToken),
+		nextSweep: time.Now().Add(time.Hour),
+	}
+}
+
+func (r *InMemoryRefreshTokenRepository) Create(token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Истёкшие токены больше не нужны даже для обнаружения повторного использования
+	now := time.Now()
+	if now.After(r.nextSweep) {
+		for hash, t := range r.tokens {
+			if now.After(t.ExpiresAt) {
+				delete(r.tokens, hash)
+			}
+		}
+		r.nextSweep = now.Add(time.Hour)
+	}
+
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (r *InMemoryRefreshTokenRepository) FindByHash(tokenHash string) (*models.RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	token, exists := r.tokens[tokenHash]
+	if !exists {
+		return nil, errors.New("refresh token not found")
+	}
+	found := *token
+	return &found, nil
+}
+
+func (r *InMemoryRefreshTokenRepository) MarkUsed(tokenHash string, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, exists := r.tokens[tokenHash]
+	if !exists {
+		return errors.New("refresh token not found")
+	}
+	if token.UsedAt != nil {
+		return ErrRefreshTokenUsed
+	}
+	token.UsedAt = &usedAt
+	return nil
+}
+
+func (r *InMemoryRefreshTokenRepository) RevokeFamily(familyID uuid.UUID, revokedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryRefreshTokenRepository) RevokeUser(userID uuid.UUID, revokedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
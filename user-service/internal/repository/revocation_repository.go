@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ChrolloLucii/control-system/shared/revocation"
+	"github.com/google/uuid"
+)
+
+// RevocationChanges — записи списка отзыва новее запрошенной версии
+type RevocationChanges struct {
+	Version int64
+	Tokens  []revocation.RevokedToken
+	Users   []revocation.RevokedUser
+}
+
+// RevocationRepository хранит список отозванных access-токенов. Каждое изменение получает
+// следующую версию общего счётчика, поэтому реплики user-service отдают клиентам одну и ту же историю.
+type RevocationRepository interface {
+	// Epoch меняется, только если список создан заново: тогда клиенты загружают его целиком
+	Epoch() (string, error)
+	RevokeToken(jti string, expiresAt time.Time) error
+	// RevokeUser отзывает токены пользователя, выданные до revokedBefore; запись нужна до expiresAt
+	RevokeUser(userID uuid.UUID, revokedBefore, expiresAt time.Time) error
+	IsTokenRevoked(jti string) (bool, error)
+	// UserRevokedBefore возвращает момент отзыва токенов пользователя или nil
+	UserRevokedBefore(userID uuid.UUID) (*time.Time, error)
+	// Changes возвращает текущую версию и записи с версией больше since
+	Changes(since int64) (*RevocationChanges, error)
+	// DeleteExpired удаляет записи, которые уже ни на что не влияют
+	DeleteExpired(now time.Time) error
+}
+
+type revokedTokenEntry struct {
+	expiresAt time.Time
+	version   int64
+}
+
+type revokedUserEntry struct {
+	revokedBefore time.Time
+	expiresAt     time.Time
+	version       int64
+}
+
+// InMemoryRevocationRepository живёт, пока работает процесс: после перезапуска эпоха новая
+type InMemoryRevocationRepository struct {
+	epoch string
+
+	mu      sync.RWMutex
+	version int64
+	tokens  map[string]revokedTokenEntry
+	users   map[uuid.UUID]revokedUserEntry
+}
+
+func NewInMemoryRevocationRepository() *InMemoryRevocationRepository {
+	return &InMemoryRevocationRepository{
+		epoch:  uuid.NewString(),
+		tokens: make(map[string]revokedTokenEntry),
+		users:  make(map[uuid.UUID]revokedUserEntry),
+	}
+}
+
+func (r *InMemoryRevocationRepository) Epoch() (string, error) {
+	return r.epoch, nil
+}
+
+func (r *InMemoryRevocationRepository) RevokeToken(jti string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.version++
+	r.tokens[jti] = revokedTokenEntry{expiresAt: expiresAt, version: r.version}
+	return nil
+}
+
+func (r *InMemoryRevocationRepository) RevokeUser(userID uuid.UUID, revokedBefore, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.version++
+	r.users[userID] = revokedUserEntry{revokedBefore: revokedBefore, expiresAt: expiresAt, version: r.version}
+	return nil
+}
+
+func (r *InMemoryRevocationRepository) IsTokenRevoked(jti string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, revoked := r.tokens[jti]
+	return revoked, nil
+}
+
+func (r *InMemoryRevocationRepository) UserRevokedBefore(userID uuid.UUID) (*time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, exists := r.users[userID]
+	if !exists {
+		return nil, nil
+	}
+	revokedBefore := user.revokedBefore
+	return &revokedBefore, nil
+}
+
+func (r *InMemoryRevocationRepository) Changes(since int64) (*RevocationChanges, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	changes := &RevocationChanges{
+		Version: r.version,
+		Tokens:  []revocation.RevokedToken{},
+		Users:   []revocation.RevokedUser{},
+	}
+	for jti, token := range r.tokens {
+		if token.version > since {
+			changes.Tokens = append(changes.Tokens, revocation.RevokedToken{JTI: jti, ExpiresAt: token.expiresAt})
+		}
+	}
+	for userID, user := range r.users {
+		if user.version > since {
+			changes.Users = append(changes.Users, revocation.RevokedUser{
+				UserID:        userID.String(),
+				RevokedBefore: user.revokedBefore,
+				ExpiresAt:     user.expiresAt,
+			})
+		}
+	}
+	return changes, nil
+}
+
+func (r *InMemoryRevocationRepository) DeleteExpired(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for jti, token := range r.tokens {
+		if now.After(token.expiresAt) {
+			delete(r.tokens, jti)
+		}
+	}
+	for userID, user := range r.users {
+		if now.After(user.expiresAt) {
+			delete(r.users, userID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"user-service/models"
+
+	"github.com/google/uuid"
+)
+
+type SQLRefreshTokenRepository struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	nextSweep time.Time
+}
+
+// NewSQLRefreshTokenRepository ожидает схему, созданную миграциями (migrations)
+func NewSQLRefreshTokenRepository(db *sql.DB) *SQLRefreshTokenRepository {
+	return &SQLRefreshTokenRepository{db: db}
+}
+
+func (r *SQLRefreshTokenRepository) Create(token *models.RefreshToken) error {
+	// Истёкшие токены больше не нужны даже для обнаружения повторного использования
+	r.sweep(time.Now())
+
+	_, err := r.db.Exec(
+		`INSERT INTO refresh_tokens (id, family_id, user_id, token_hash, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		token.ID.String(), token.FamilyID.String(), token.UserID.String(), token.TokenHash, token.CreatedAt.UTC(), token.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+func (r *SQLRefreshTokenRepository) FindByHash(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	var id, familyID, userID string
+	var usedAt, revokedAt sql.NullTime
+	err := r.db.QueryRow(
+		`SELECT id, family_id, user_id, token_hash, created_at, expires_at, used_at, revoked_at FROM refresh_tokens WHERE token_hash = $1`,
+		tokenHash,
+	).Scan(&id, &familyID, &userID, &token.TokenHash, &token.CreatedAt, &token.ExpiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("refresh token not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if token.ID, err = uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("invalid refresh token id %q: %w", id, err)
+	}
+	if token.FamilyID, err = uuid.Parse(familyID); err != nil {
+		return nil, fmt.Errorf("invalid refresh token family %q: %w", familyID, err)
+	}
+	if token.UserID, err = uuid.Parse(userID); err != nil {
+		return nil, fmt.Errorf("invalid refresh token user %q: %w", userID, err)
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+// MarkUsed — условное обновление: из параллельных обменов одного токена проходит один
+func (r *SQLRefreshTokenRepository) MarkUsed(tokenHash string, usedAt time.Time) error {
+	result, err := r.db.Exec(
+		`UPDATE refresh_tokens SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL`,
+		usedAt.UTC(), tokenHash,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 1 {
+		return nil
+	}
+
+	var exists bool
+	if err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE token_hash = $1)`, tokenHash).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrRefreshTokenUsed
+	}
+	return errors.New("refresh token not found")
+}
+
+func (r *SQLRefreshTokenRepository) RevokeFamily(familyID uuid.UUID, revokedAt time.Time) error {
+	_, err := r.db.Exec(
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`,
+		revokedAt.UTC(), familyID.String(),
+	)
+	return err
+}
+
+func (r *SQLRefreshTokenRepository) RevokeUser(userID uuid.UUID, revokedAt time.Time) error {
+	_, err := r.db.Exec(
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`,
+		revokedAt.UTC(), userID.String(),
+	)
+	return err
+}
+
+// sweep раз в час удаляет истёкшие токены; реплики делают это независимо
+func (r *SQLRefreshTokenRepository) sweep(now time.Time) {
+	r.mu.Lock()
+	if now.Before(r.nextSweep) {
+		r.mu.Unlock()
+		return
+	}
+	r.nextSweep = now.Add(time.Hour)
+	r.mu.Unlock()
+
+	if _, err := r.db.Exec(`DELETE FROM refresh_tokens WHERE expires_at < $1`, now.UTC()); err != nil {
+		log.Printf("Failed to delete expired refresh tokens: %v", err)
+	}
+}
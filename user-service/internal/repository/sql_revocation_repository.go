@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ChrolloLucii/control-system/shared/revocation"
+	"github.com/google/uuid"
+)
+
+type SQLRevocationRepository struct {
+	db *sql.DB
+
+	mu    sync.Mutex
+	epoch string
+}
+
+// NewSQLRevocationRepository ожидает схему, созданную миграциями (migrations)
+func NewSQLRevocationRepository(db *sql.DB) *SQLRevocationRepository {
+	return &SQLRevocationRepository{db: db}
+}
+
+// Epoch записывается первой репликой, которая к ней обратилась, и дальше не меняется
+func (r *SQLRevocationRepository) Epoch() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.epoch != "" {
+		return r.epoch, nil
+	}
+
+	if _, err := r.db.Exec(
+		`INSERT INTO revocation_state (id, epoch, version) VALUES (1, $1, 0) ON CONFLICT (id) DO NOTHING`,
+		uuid.NewString(),
+	); err != nil {
+		return "", err
+	}
+	if err := r.db.QueryRow(`SELECT epoch FROM revocation_state WHERE id = 1`).Scan(&r.epoch); err != nil {
+		return "", err
+	}
+	return r.epoch, nil
+}
+
+func (r *SQLRevocationRepository) RevokeToken(jti string, expiresAt time.Time) error {
+	return r.withNextVersion(func(tx *sql.Tx, version int64) error {
+		_, err := tx.Exec(
+			`INSERT INTO revoked_tokens (jti, expires_at, version) VALUES ($1, $2, $3)
+			ON CONFLICT (jti) DO UPDATE SET expires_at = excluded.expires_at, version = excluded.version`,
+			jti, expiresAt.UTC(), version,
+		)
+		return err
+	})
+}
+
+func (r *SQLRevocationRepository) RevokeUser(userID uuid.UUID, revokedBefore, expiresAt time.Time) error {
+	return r.withNextVersion(func(tx *sql.Tx, version int64) error {
+		_, err := tx.Exec(
+			`INSERT INTO revoked_users (user_id, revoked_before, expires_at, version) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id) DO UPDATE SET revoked_before = excluded.revoked_before, expires_at = excluded.expires_at, version = excluded.version`,
+			userID.String(), revokedBefore.UTC(), expiresAt.UTC(), version,
+		)
+		return err
+	})
+}
+
+// withNextVersion увеличивает общий счётчик и записывает изменение в одной транзакции:
+// клиент, прочитавший версию, уже видит все записи до неё включительно
+func (r *SQLRevocationRepository) withNextVersion(write func(tx *sql.Tx, version int64) error) error {
+	if _, err := r.Epoch(); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// UPDATE первым берёт блокировку записи, поэтому параллельные изменения получают разные версии
+	if _, err := tx.Exec(`UPDATE revocation_state SET version = version + 1 WHERE id = 1`); err != nil {
+		return err
+	}
+	var version int64
+	if err := tx.QueryRow(`SELECT version FROM revocation_state WHERE id = 1`).Scan(&version); err != nil {
+		return err
+	}
+	if err := write(tx, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *SQLRevocationRepository) IsTokenRevoked(jti string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	return revoked, err
+}
+
+func (r *SQLRevocationRepository) UserRevokedBefore(userID uuid.UUID) (*time.Time, error) {
+	var revokedBefore time.Time
+	err := r.db.QueryRow(`SELECT revoked_before FROM revoked_users WHERE user_id = $1`, userID.String()).Scan(&revokedBefore)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revokedBefore, nil
+}
+
+func (r *SQLRevocationRepository) Changes(since int64) (*RevocationChanges, error) {
+	if _, err := r.Epoch(); err != nil {
+		return nil, err
+	}
+
+	// Версия и записи читаются одним снимком, иначе запись новее версии пришла бы клиенту дважды,
+	// а запись, закоммиченная между запросами, могла бы потеряться
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	changes := &RevocationChanges{
+		Tokens: []revocation.RevokedToken{},
+		Users:  []revocation.RevokedUser{},
+	}
+	if err := tx.QueryRow(`SELECT version FROM revocation_state WHERE id = 1`).Scan(&changes.Version); err != nil {
+		return nil, err
+	}
+
+	tokenRows, err := tx.Query(`SELECT jti, expires_at FROM revoked_tokens WHERE version > $1 AND version <= $2`, since, changes.Version)
+	if err != nil {
+		return nil, err
+	}
+	defer tokenRows.Close()
+	for tokenRows.Next() {
+		var token revocation.RevokedToken
+		if err := tokenRows.Scan(&token.JTI, &token.ExpiresAt); err != nil {
+			return nil, err
+		}
+		changes.Tokens = append(changes.Tokens, token)
+	}
+	if err := tokenRows.Err(); err != nil {
+		return nil, err
+	}
+
+	userRows, err := tx.Query(`SELECT user_id, revoked_before, expires_at FROM revoked_users WHERE version > $1 AND version <= $2`, since, changes.Version)
+	if err != nil {
+		return nil, err
+	}
+	defer userRows.Close()
+	for userRows.Next() {
+		var user revocation.RevokedUser
+		if err := userRows.Scan(&user.UserID, &user.RevokedBefore, &user.ExpiresAt); err != nil {
+			return nil, err
+		}
+		changes.Users = append(changes.Users, user)
+	}
+	if err := userRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (r *SQLRevocationRepository) DeleteExpired(now time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < $1`, now.UTC()); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(`DELETE FROM revoked_users WHERE expires_at < $1`, now.UTC())
+	return err
+}
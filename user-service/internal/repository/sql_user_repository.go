@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"user-service/models"
+
+	"github.com/google/uuid"
+)
+
+type SQLUserRepository struct {
+	db *sql.DB
+}
+
+// NewSQLUserRepository ожидает схему, созданную миграциями (migrations)
+func NewSQLUserRepository(db *sql.DB) *SQLUserRepository {
+	return &SQLUserRepository{db: db}
+}
+
+func (r *SQLUserRepository) Create(user *models.User) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO users (id, email, password_hash, name, version, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		user.ID.String(), user.Email, user.Password, user.Name, user.Version, user.CreatedAt.UTC(), user.UpdatedAt.UTC(),
+	)
+	if isUniqueViolation(err) {
+		return errors.New("user with this email already exists")
+	}
+	if err != nil {
+		return err
+	}
+	if err := insertRoles(tx, user); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLUserRepository) FindByID(id uuid.UUID) (*models.User, error) {
+	return r.findOne(`SELECT id, email, password_hash, name, version, created_at, updated_at FROM users WHERE id = $1`, id.String())
+}
+
+func (r *SQLUserRepository) FindByEmail(email string) (*models.User, error) {
+	return r.findOne(`SELECT id, email, password_hash, name, version, created_at, updated_at FROM users WHERE lower(email) = lower($1)`, email)
+}
+
+// Update — сравнение с записью по версии: из параллельных изменений одной версии проходит одно
+func (r *SQLUserRepository) Update(user *models.User) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE users SET email = $1, password_hash = $2, name = $3, updated_at = $4, version = version + 1 WHERE id = $5 AND version = $6`,
+		user.Email, user.Password, user.Name, user.UpdatedAt.UTC(), user.ID.String(), user.Version,
+	)
+	if isUniqueViolation(err) {
+		return errors.New("user with this email already exists")
+	}
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, user.ID.String()).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrUserVersionConflict
+		}
+		return errors.New("user not found")
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_roles WHERE user_id = $1`, user.ID.String()); err != nil {
+		return err
+	}
+	if err := insertRoles(tx, user); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	user.Version++
+	return nil
+}
+
+func (r *SQLUserRepository) FindAll(page, limit int, role string) ([]*models.User, int, error) {
+	// Пустая роль — без фильтра
+	const filter = `($1 = '' OR EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id AND user_roles.role = $1))`
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM users WHERE `+filter, role).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(
+		`SELECT id, email, password_hash, name, version, created_at, updated_at FROM users WHERE `+filter+`
+		ORDER BY created_at, id LIMIT $2 OFFSET $3`,
+		role, limit, (page-1)*limit,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.loadRoles(users); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *SQLUserRepository) FindPage(role string, seek *UserSeek, limit int) ([]*models.User, bool, error) {
+	query := `SELECT id, email, password_hash, name, version, created_at, updated_at FROM users
+		WHERE ($1 = '' OR EXISTS (SELECT 1 FROM user_roles WHERE user_roles.user_id = users.id AND user_roles.role = $1))`
+	args := []interface{}{role}
+
+	// Назад по выборке — обратный порядок, результат потом разворачивается
+	op, direction := ">", "ASC"
+	if seek != nil && seek.Backward {
+		op, direction = "<", "DESC"
+	}
+	if seek != nil {
+		query += fmt.Sprintf(" AND (created_at %[1]s $2 OR (created_at = $2 AND id %[1]s $3))", op)
+		args = append(args, seek.CreatedAt.UTC(), seek.ID.String())
+	}
+	query += fmt.Sprintf(" ORDER BY created_at %[1]s, id %[1]s LIMIT $%[2]d", direction, len(args)+1)
+
+	rows, err := r.db.Query(query, append(args, limit+1)...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, false, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+	if seek != nil && seek.Backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	if err := r.loadRoles(users); err != nil {
+		return nil, false, err
+	}
+	return users, hasMore, nil
+}
+
+func (r *SQLUserRepository) findOne(query string, arg interface{}) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRow(query, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadRoles([]*models.User{user}); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// loadRoles загружает роли для страницы пользователей одним запросом
+func (r *SQLUserRepository) loadRoles(users []*models.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*models.User, len(users))
+	placeholders := make([]string, 0, len(users))
+	args := make([]interface{}, 0, len(users))
+	for i, user := range users {
+		user.Roles = []string{}
+		byID[user.ID.String()] = user
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		args = append(args, user.ID.String())
+	}
+
+	rows, err := r.db.Query(
+		`SELECT user_id, role FROM user_roles WHERE user_id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY role`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, role string
+		if err := rows.Scan(&userID, &role); err != nil {
+			return err
+		}
+		if user, exists := byID[userID]; exists {
+			user.Roles = append(user.Roles, role)
+		}
+	}
+	return rows.Err()
+}
+
+func insertRoles(tx *sql.Tx, user *models.User) error {
+	for _, role := range user.Roles {
+		if _, err := tx.Exec(`INSERT INTO user_roles (user_id, role) VALUES ($1, $2)`, user.ID.String(), role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var id string
+	if err := row.Scan(&id, &user.Email, &user.Password, &user.Name, &user.Version, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id %q: %w", id, err)
+	}
+	user.ID = parsed
+	return &user, nil
+}
+
+// isUniqueViolation распознаёт нарушение уникальности в SQLite и PostgreSQL (SQLSTATE 23505)
+func isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return strings.Contains(message, "UNIQUE constraint failed") ||
+		strings.Contains(message, "duplicate key value") ||
+		strings.Contains(message, "23505")
+}
@@ -2,20 +2,44 @@ package repository
 
 import (
 	"errors"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 	"user-service/models"
 
 	"github.com/google/uuid"
 )
 
+// ErrUserVersionConflict — пользователь изменён после того, как его прочитали
+var ErrUserVersionConflict = errors.New("user has been modified by another request")
+
 type UserRepository interface {
 	Create(user *models.User) error
 	FindByID(id uuid.UUID) (*models.User, error)
 	FindByEmail(email string) (*models.User, error)
+	// Update сохраняет пользователя, только если в хранилище та же версия, что в user,
+	// и увеличивает user.Version; иначе ErrUserVersionConflict
 	Update(user *models.User) error
 	FindAll(page, limit int, role string) ([]*models.User, int, error)
+	// FindPage — выборка по курсору в порядке (createdAt, id): до limit пользователей
+	// после seek (nil — с начала) и признак, что в направлении обхода есть ещё
+	FindPage(role string, seek *UserSeek, limit int) ([]*models.User, bool, error)
+}
+
+// UserSeek — позиция курсора: пользователь, после которого (Backward — до которого) продолжается выборка
+type UserSeek struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	Backward  bool
 }
 
+func SeekFor(user *models.User, backward bool) *UserSeek {
+	return &UserSeek{CreatedAt: user.CreatedAt, ID: user.ID, Backward: backward}
+}
+
+// InMemoryUserRepository хранит копии пользователей: изменения вызывающего не видны
+// другим, пока не сохранены через Update
 type InMemoryUserRepository struct {
 	users map[uuid.UUID]*models.User
 	mu    sync.RWMutex
@@ -31,13 +55,11 @@ func (r *InMemoryUserRepository) Create(user *models.User) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	for _, u := range r.users {
-		if u.Email == user.Email {
-			return errors.New("user with this email already exists")
-		}
+	if r.emailTaken(user.Email, user.ID) {
+		return errors.New("user with this email already exists")
 	}
 
-	r.users[user.ID] = user
+	r.users[user.ID] = copyUser(user)
 	return nil
 }
 
@@ -49,7 +71,7 @@ func (r *InMemoryUserRepository) FindByID(id uuid.UUID) (*models.User, error) {
 	if !exists {
 		return nil, errors.New("user not found")
 	}
-	return user, nil
+	return copyUser(user), nil
 }
 
 func (r *InMemoryUserRepository) FindByEmail(email string) (*models.User, error) {
@@ -57,22 +79,41 @@ func (r *InMemoryUserRepository) FindByEmail(email string) (*models.User, error)
 	defer r.mu.RUnlock()
 
 	for _, user := range r.users {
-		if user.Email == email {
-			return user, nil
+		if strings.EqualFold(user.Email, email) {
+			return copyUser(user), nil
 		}
 	}
 	return nil, errors.New("user not found")
 }
 
+// emailTaken — email занят другим пользователем. Регистр не учитывается, как в уникальном
+// индексе users_email_lower_idx SQL-хранилища.
+func (r *InMemoryUserRepository) emailTaken(email string, exceptID uuid.UUID) bool {
+	for _, user := range r.users {
+		if user.ID != exceptID && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *InMemoryUserRepository) Update(user *models.User) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.users[user.ID]; !exists {
+	stored, exists := r.users[user.ID]
+	if !exists {
 		return errors.New("user not found")
 	}
+	if stored.Version != user.Version {
+		return ErrUserVersionConflict
+	}
+	if r.emailTaken(user.Email, user.ID) {
+		return errors.New("user with this email already exists")
+	}
 
-	r.users[user.ID] = user
+	user.Version++
+	r.users[user.ID] = copyUser(user)
 	return nil
 }
 
@@ -80,6 +121,49 @@ func (r *InMemoryUserRepository) FindAll(page, limit int, role string) ([]*model
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	filtered := r.withRole(role)
+
+	total := len(filtered)
+	start := (page - 1) * limit
+	end := start + limit
+
+	if start > total {
+		return []*models.User{}, total, nil
+	}
+	if end > total {
+		end = total
+	}
+
+	return filtered[start:end], total, nil
+}
+
+func (r *InMemoryUserRepository) FindPage(role string, seek *UserSeek, limit int) ([]*models.User, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := []*models.User{}
+	for _, user := range r.withRole(role) {
+		if seek != nil {
+			key := &models.User{ID: seek.ID, CreatedAt: seek.CreatedAt}
+			if seek.Backward && !userLess(user, key) || !seek.Backward && !userLess(key, user) {
+				continue
+			}
+		}
+		users = append(users, user)
+	}
+
+	if len(users) <= limit {
+		return users, false, nil
+	}
+	if seek != nil && seek.Backward {
+		// Назад берутся ближайшие к курсору, то есть последние
+		return users[len(users)-limit:], true, nil
+	}
+	return users[:limit], true, nil
+}
+
+// withRole возвращает пользователей с ролью (пустая — всех) в порядке (createdAt, id), как в SQL-хранилище
+func (r *InMemoryUserRepository) withRole(role string) []*models.User {
 	var filtered []*models.User
 	for _, user := range r.users {
 		if role != "" {
@@ -94,19 +178,24 @@ func (r *InMemoryUserRepository) FindAll(page, limit int, role string) ([]*model
 				continue
 			}
 		}
-		filtered = append(filtered, user)
+		filtered = append(filtered, copyUser(user))
 	}
 
-	total := len(filtered)
-	start := (page - 1) * limit
-	end := start + limit
+	sort.Slice(filtered, func(i, j int) bool {
+		return userLess(filtered[i], filtered[j])
+	})
+	return filtered
+}
 
-	if start > total {
-		return []*models.User{}, total, nil
-	}
-	if end > total {
-		end = total
-	}
+func copyUser(user *models.User) *models.User {
+	copied := *user
+	copied.Roles = append([]string(nil), user.Roles...)
+	return &copied
+}
 
-	return filtered[start:end], total, nil
+func userLess(a, b *models.User) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID.String() < b.ID.String()
 }
@@ -2,10 +2,11 @@ package service
 
 import (
 	"errors"
-	"os"
+	"fmt"
 	"time"
 	"user-service/models"
 
+	"github.com/ChrolloLucii/control-system/shared/jwks"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
@@ -17,25 +18,28 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+var ErrTokenRevoked = errors.New("token has been revoked")
+
 type JWTService interface {
 	GenerateToken(user *models.User) (string, error)
 	ValidateToken(tokenString string) (*Claims, error)
+	// ExpiresIn — срок жизни access-токена
+	ExpiresIn() time.Duration
+	// JWKS — публичные ключи для проверки токенов другими сервисами
+	JWKS() jwks.Set
 }
 
 type jwtService struct {
-	secretKey string
-	expiresIn time.Duration
+	keys        *KeyManager
+	revocations *RevocationList
+	expiresIn   time.Duration
 }
 
-func NewJWTService() JWTService {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key-change-in-production"
-	}
-
+func NewJWTService(keys *KeyManager, revocations *RevocationList, expiresIn time.Duration) JWTService {
 	return &jwtService{
-		secretKey: secret,
-		expiresIn: 24 * time.Hour,
+		keys:        keys,
+		revocations: revocations,
+		expiresIn:   expiresIn,
 	}
 }
 
@@ -45,30 +49,50 @@ func (s *jwtService) GenerateToken(user *models.User) (string, error) {
 		Email:  user.Email,
 		Roles:  user.Roles,
 		RegisteredClaims: jwt.RegisteredClaims{
+			// jti позволяет отозвать отдельный токен
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.expiresIn)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
+	key := s.keys.activeKey()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.signer)
 }
 
 func (s *jwtService) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys.key(kid)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", jwks.ErrKeyNotFound, kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, errors.New("unexpected signing method")
 		}
-		return []byte(s.secretKey), nil
-	})
+		return key.signer.Public(), nil
+	}, jwt.WithValidMethods(jwks.ValidMethods))
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		if s.revocations.IsRevoked(claims) {
+			return nil, ErrTokenRevoked
+		}
 		return claims, nil
 	}
 
 	return nil, errors.New("invalid token")
 }
+
+func (s *jwtService) ExpiresIn() time.Duration {
+	return s.expiresIn
+}
+
+func (s *jwtService) JWKS() jwks.Set {
+	return s.keys.JWKS()
+}
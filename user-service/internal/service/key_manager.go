@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChrolloLucii/control-system/shared/jwks"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type signingKey struct {
+	kid       string
+	signer    crypto.Signer
+	method    jwt.SigningMethod
+	createdAt time.Time
+}
+
+// KeyManager хранит ключи подписи токенов и ротирует их.
+// Новым ключом подписываются все новые токены, а прежние ключи ещё overlap публикуются в JWKS
+// и принимаются при проверке, чтобы выданные ими токены дожили до истечения.
+// Ключи лежат в dir в PEM (PKCS#8), имя файла — kid; каталог можно сделать общим для реплик.
+type KeyManager struct {
+	dir              string
+	algorithm        string
+	rotationInterval time.Duration
+	overlap          time.Duration
+
+	mu sync.RWMutex
+	// По возрастанию createdAt, последний — действующий
+	keys []*signingKey
+}
+
+func NewKeyManager(dir, algorithm string, rotationInterval, overlap time.Duration) (*KeyManager, error) {
+	if algorithm != jwks.AlgorithmRS256 && algorithm != jwks.AlgorithmES256 {
+		return nil, fmt.Errorf("unsupported JWT algorithm %q, expected RS256 or ES256", algorithm)
+	}
+
+	m := &KeyManager{
+		dir:              dir,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		overlap:          overlap,
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.maintain(time.Now()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Run раз в минуту подхватывает ключи других реплик, ротирует и удаляет ключи с истёкшим overlap
+func (m *KeyManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := m.maintain(now); err != nil {
+				log.Printf("Signing key maintenance failed: %v", err)
+			}
+		}
+	}
+}
+
+func (m *KeyManager) maintain(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.load(); err != nil {
+		return err
+	}
+
+	active := m.active()
+	if active == nil || active.method.Alg() != m.algorithm || now.Sub(active.createdAt) >= m.rotationInterval {
+		if err := m.rotate(now); err != nil {
+			return err
+		}
+	}
+
+	// Ключ выводится из оборота, когда появляется следующий, и ещё overlap остаётся в JWKS
+	var keys []*signingKey
+	for i, key := range m.keys {
+		if i < len(m.keys)-1 && now.Sub(m.keys[i+1].createdAt) > m.overlap {
+			log.Printf("Signing key %s expired", key.kid)
+			m.remove(key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	m.keys = keys
+	return nil
+}
+
+func (m *KeyManager) rotate(now time.Time) error {
+	var signer crypto.Signer
+	var err error
+	if m.algorithm == jwks.AlgorithmRS256 {
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		return err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	key := &signingKey{
+		kid:       hex.EncodeToString(id),
+		signer:    signer,
+		method:    signingMethod(signer),
+		createdAt: now,
+	}
+	if err := m.save(key); err != nil {
+		return err
+	}
+
+	m.keys = append(m.keys, key)
+	log.Printf("Signing key rotated, active kid %s (%s)", key.kid, m.algorithm)
+	return nil
+}
+
+// load добавляет ключи из каталога, которых ещё нет в памяти
+func (m *KeyManager) load() error {
+	if m.dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(m.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool, len(m.keys))
+	for _, key := range m.keys {
+		known[key.kid] = true
+	}
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		if known[kid] {
+			continue
+		}
+		key, err := readSigningKey(file, kid)
+		if err != nil {
+			return err
+		}
+		m.keys = append(m.keys, key)
+	}
+
+	sort.Slice(m.keys, func(i, j int) bool { return m.keys[i].createdAt.Before(m.keys[j].createdAt) })
+	return nil
+}
+
+func (m *KeyManager) save(key *signingKey) error {
+	if m.dir == "" {
+		return nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.signer)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{"Created": key.createdAt.UTC().Format(time.RFC3339)},
+		Bytes:   der,
+	})
+	return os.WriteFile(filepath.Join(m.dir, key.kid+".pem"), data, 0o600)
+}
+
+func (m *KeyManager) remove(key *signingKey) {
+	if m.dir == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(m.dir, key.kid+".pem")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Failed to remove signing key %s: %v", key.kid, err)
+	}
+}
+
+func (m *KeyManager) active() *signingKey {
+	if len(m.keys) == 0 {
+		return nil
+	}
+	return m.keys[len(m.keys)-1]
+}
+
+// activeKey возвращает ключ для подписи новых токенов
+func (m *KeyManager) activeKey() *signingKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.active()
+}
+
+// key ищет ключ по kid среди действующего и ещё не истёкших прежних
+func (m *KeyManager) key(kid string) (*signingKey, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.keys {
+		if key.kid == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// JWKS возвращает публичные части всех ключей, которыми могут быть подписаны действующие токены
+func (m *KeyManager) JWKS() jwks.Set {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := jwks.Set{Keys: make([]jwks.Key, 0, len(m.keys))}
+	for _, key := range m.keys {
+		jwk, err := jwks.NewKey(key.kid, key.signer.Public())
+		if err != nil {
+			log.Printf("Failed to publish signing key %s: %v", key.kid, err)
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func readSigningKey(file, kid string) (*signingKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", file)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	var signer crypto.Signer
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		signer = key
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s: only P-256 EC keys are supported", file)
+		}
+		signer = key
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type %T", file, parsed)
+	}
+
+	// Время создания хранится в заголовке PEM, для ключей, положенных вручную, — время изменения файла
+	createdAt, err := time.Parse(time.RFC3339, block.Headers["Created"])
+	if err != nil {
+		info, statErr := os.Stat(file)
+		if statErr != nil {
+			return nil, statErr
+		}
+		createdAt = info.ModTime()
+	}
+
+	return &signingKey{
+		kid:       kid,
+		signer:    signer,
+		method:    signingMethod(signer),
+		createdAt: createdAt,
+	}, nil
+}
+
+func signingMethod(signer crypto.Signer) jwt.SigningMethod {
+	if _, ok := signer.(*rsa.PrivateKey); ok {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodES256
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+	"user-service/internal/repository"
+
+	"github.com/ChrolloLucii/control-system/shared/revocation"
+	"github.com/google/uuid"
+)
+
+// Как часто ждущий запрос перечитывает хранилище: изменения других реплик
+// не будят его локально
+const revocationPollInterval = time.Second
+
+// Как часто из хранилища удаляются истёкшие записи
+const revocationPruneInterval = time.Minute
+
+// RevocationList — список отозванных access-токенов: отдельные токены по jti
+// и «все токены пользователя, выданные раньше T». Каждое изменение получает версию,
+// чтобы шлюз и сервисы забирали только новые записи.
+type RevocationList struct {
+	// Список хранится в репозитории пользователей: переживает перезапуск и общий у реплик
+	repo      repository.RevocationRepository
+	accessTTL time.Duration
+
+	mu        sync.Mutex
+	nextPrune time.Time
+	// Закрывается при каждом изменении, чтобы разбудить ждущие запросы
+	changed chan struct{}
+}
+
+func NewRevocationList(repo repository.RevocationRepository, accessTTL time.Duration) *RevocationList {
+	return &RevocationList{
+		repo:      repo,
+		accessTTL: accessTTL,
+		changed:   make(chan struct{}),
+	}
+}
+
+// RevokeToken отзывает один токен до его истечения
+func (l *RevocationList) RevokeToken(jti string, expiresAt time.Time) error {
+	if err := l.repo.RevokeToken(jti, expiresAt); err != nil {
+		return err
+	}
+	l.notify()
+	return nil
+}
+
+// RevokeUser отзывает все токены пользователя, выданные до before
+func (l *RevocationList) RevokeUser(userID uuid.UUID, before time.Time) error {
+	if err := l.repo.RevokeUser(userID, before, before.Add(l.accessTTL)); err != nil {
+		return err
+	}
+	l.notify()
+	return nil
+}
+
+// IsRevoked при недоступном хранилище считает токен отозванным: лучше отказать, чем пропустить
+func (l *RevocationList) IsRevoked(claims *Claims) bool {
+	if claims.ID != "" {
+		revoked, err := l.repo.IsTokenRevoked(claims.ID)
+		if err != nil {
+			log.Printf("Failed to check token revocation: %v", err)
+			return true
+		}
+		if revoked {
+			return true
+		}
+	}
+	if claims.IssuedAt == nil {
+		return false
+	}
+
+	revokedBefore, err := l.repo.UserRevokedBefore(claims.UserID)
+	if err != nil {
+		log.Printf("Failed to check user revocation: %v", err)
+		return true
+	}
+	return revokedBefore != nil && revocation.IsRevokedBefore(claims.IssuedAt.Time, *revokedBefore)
+}
+
+// Changes возвращает записи новее since. Если клиент пришёл с другой эпохой — весь список.
+// При wait > 0 и отсутствии изменений запрос ждёт их до wait (long polling).
+func (l *RevocationList) Changes(ctx context.Context, epoch string, since int64, wait time.Duration) (revocation.Changes, error) {
+	l.prune(time.Now())
+
+	currentEpoch, err := l.repo.Epoch()
+	if err != nil {
+		return revocation.Changes{}, err
+	}
+	full := epoch != currentEpoch
+	if full {
+		since = 0
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		// Канал берётся до чтения, чтобы не пропустить изменение между чтением и ожиданием
+		l.mu.Lock()
+		changed := l.changed
+		l.mu.Unlock()
+
+		stored, err := l.repo.Changes(since)
+		if err != nil {
+			return revocation.Changes{}, err
+		}
+		remaining := time.Until(deadline)
+		if full || stored.Version > since || remaining <= 0 {
+			return revocation.Changes{
+				Epoch:   currentEpoch,
+				Version: stored.Version,
+				Full:    full,
+				Tokens:  stored.Tokens,
+				Users:   stored.Users,
+			}, nil
+		}
+
+		timer := time.NewTimer(min(remaining, revocationPollInterval))
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-ctx.Done():
+			// Клиент ушёл: отдаём то, что есть
+			deadline = time.Now()
+		}
+		timer.Stop()
+	}
+}
+
+func (l *RevocationList) notify() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// prune раз в revocationPruneInterval удаляет записи, которые уже ни на что не влияют:
+// отозванные токены истекли сами
+func (l *RevocationList) prune(now time.Time) {
+	l.mu.Lock()
+	if now.Before(l.nextPrune) {
+		l.mu.Unlock()
+		return
+	}
+	l.nextPrune = now.Add(revocationPruneInterval)
+	l.mu.Unlock()
+
+	if err := l.repo.DeleteExpired(now); err != nil {
+		log.Printf("Failed to delete expired revocations: %v", err)
+	}
+}
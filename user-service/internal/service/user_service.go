@@ -1,32 +1,66 @@
 package service
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log"
 	"time"
 	"user-service/internal/dto"
 	"user-service/internal/repository"
 	"user-service/models"
 
+	"github.com/ChrolloLucii/control-system/shared/etag"
 	"github.com/google/uuid"
 )
 
+// Сколько раз изменение без If-Match повторяется, если пользователя успели изменить параллельно
+const maxUpdateAttempts = 3
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, please log in again")
+	ErrRevocationFailed    = errors.New("failed to revoke tokens")
+)
+
+// TokenPair — короткоживущий access-токен и refresh-токен для его обновления
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
 type UserService interface {
 	Register(req *dto.RegisterRequest) (*models.User, error)
-	Login(req *dto.LoginRequest) (*models.User, string, error)
+	Login(req *dto.LoginRequest) (*models.User, *TokenPair, error)
+	RefreshTokens(refreshToken string) (*TokenPair, error)
+	Logout(refreshToken string, accessToken *Claims) error
+	RevokeUserTokens(userID uuid.UUID) error
 	GetProfile(userID uuid.UUID) (*models.User, error)
-	UpdateProfile(userID uuid.UUID, req *dto.UpdateProfileRequest) (*models.User, error)
+	// ifMatch — версии из If-Match; если профиль другой версии, repository.ErrUserVersionConflict
+	UpdateProfile(userID uuid.UUID, req *dto.UpdateProfileRequest, ifMatch etag.Condition) (*models.User, error)
 	GetUsers(page, limit int, role string) ([]*models.User, int, error)
+	GetUsersPage(role string, seek *repository.UserSeek, limit int) ([]*models.User, bool, error)
 }
 
 type userService struct {
-	repo       repository.UserRepository
-	jwtService JWTService
+	repo             repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	jwtService       JWTService
+	revocations      *RevocationList
+	refreshTokenTTL  time.Duration
 }
 
-func NewUserService(repo repository.UserRepository, jwtService JWTService) UserService {
+func NewUserService(repo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository, jwtService JWTService, revocations *RevocationList, refreshTokenTTL time.Duration) UserService {
 	return &userService{
-		repo:       repo,
-		jwtService: jwtService,
+		repo:             repo,
+		refreshTokenRepo: refreshTokenRepo,
+		jwtService:       jwtService,
+		revocations:      revocations,
+		refreshTokenTTL:  refreshTokenTTL,
 	}
 }
 
@@ -49,45 +83,163 @@ func (s *userService) Register(req *dto.RegisterRequest) (*models.User, error) {
 	return user, nil
 }
 
-func (s *userService) Login(req *dto.LoginRequest) (*models.User, string, error) {
+func (s *userService) Login(req *dto.LoginRequest) (*models.User, *TokenPair, error) {
 	user, err := s.repo.FindByEmail(req.Email)
 	if err != nil {
-		return nil, "", errors.New("invalid credentials")
+		return nil, nil, errors.New("invalid credentials")
 	}
 
 	if !user.CheckPassword(req.Password) {
-		return nil, "", errors.New("invalid credentials")
+		return nil, nil, errors.New("invalid credentials")
 	}
 
-	token, err := s.jwtService.GenerateToken(user)
+	// Каждый вход начинает новое семейство refresh-токенов
+	tokens, err := s.issueTokens(user, uuid.New())
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return user, token, nil
+	return user, tokens, nil
 }
 
-func (s *userService) GetProfile(userID uuid.UUID) (*models.User, error) {
-	return s.repo.FindByID(userID)
-}
+// RefreshTokens обменивает refresh-токен на новую пару. Каждый refresh-токен одноразовый:
+// если уже обменянный токен предъявлен снова, им воспользовался кто-то ещё,
+// поэтому отзывается всё семейство — и у злоумышленника, и у владельца.
+func (s *userService) RefreshTokens(refreshToken string) (*TokenPair, error) {
+	stored, err := s.refreshTokenRepo.FindByHash(hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
 
-func (s *userService) UpdateProfile(userID uuid.UUID, req *dto.UpdateProfileRequest) (*models.User, error) {
-	user, err := s.repo.FindByID(userID)
+	err = s.refreshTokenRepo.MarkUsed(stored.TokenHash, time.Now())
+	if errors.Is(err, repository.ErrRefreshTokenUsed) {
+		log.Printf("Refresh token reuse detected for user %s, revoking token family %s", stored.UserID, stored.FamilyID)
+		if err := s.refreshTokenRepo.RevokeFamily(stored.FamilyID, time.Now()); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	user.Name = req.Name
-	user.UpdatedAt = time.Now()
+	// Роли берутся заново: изменения вступают в силу при следующем обновлении
+	user, err := s.repo.FindByID(stored.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.issueTokens(user, stored.FamilyID)
+}
+
+// Logout отзывает семейство refresh-токена: ни он, ни выданные из него токены больше не обновятся.
+// Если передан действующий access-токен, он тоже отзывается, не дожидаясь истечения.
+// Неизвестный refresh-токен не считается ошибкой — результат для клиента тот же.
+func (s *userService) Logout(refreshToken string, accessToken *Claims) error {
+	if accessToken != nil && accessToken.ID != "" && accessToken.ExpiresAt != nil {
+		if err := s.revocations.RevokeToken(accessToken.ID, accessToken.ExpiresAt.Time); err != nil {
+			return fmt.Errorf("%w: %v", ErrRevocationFailed, err)
+		}
+	}
+
+	stored, err := s.refreshTokenRepo.FindByHash(hashRefreshToken(refreshToken))
+	if err != nil {
+		return nil
+	}
+	return s.refreshTokenRepo.RevokeFamily(stored.FamilyID, time.Now())
+}
+
+// RevokeUserTokens завершает все сессии пользователя: отзывает выданные access- и refresh-токены.
+// Нужен при смене пароля, снятии роли или подозрении на компрометацию.
+func (s *userService) RevokeUserTokens(userID uuid.UUID) error {
+	if _, err := s.repo.FindByID(userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := s.revocations.RevokeUser(userID, now); err != nil {
+		return fmt.Errorf("%w: %v", ErrRevocationFailed, err)
+	}
+	if err := s.refreshTokenRepo.RevokeUser(userID, now); err != nil {
+		return fmt.Errorf("%w: %v", ErrRevocationFailed, err)
+	}
+	return nil
+}
 
-	err = s.repo.Update(user)
+func (s *userService) issueTokens(user *models.User, familyID uuid.UUID) (*TokenPair, error) {
+	accessToken, err := s.jwtService.GenerateToken(user)
 	if err != nil {
 		return nil, err
 	}
 
-	return user, nil
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	err = s.refreshTokenRepo.Create(&models.RefreshToken{
+		ID:        uuid.New(),
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		TokenHash: hashRefreshToken(refreshToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.refreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.jwtService.ExpiresIn(),
+	}, nil
+}
+
+// Токен случайный и длинный, поэтому для хранения достаточно SHA-256 без соли
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *userService) GetProfile(userID uuid.UUID) (*models.User, error) {
+	return s.repo.FindByID(userID)
+}
+
+// UpdateProfile без If-Match применяет изменение к свежей версии, если профиль изменили параллельно
+func (s *userService) UpdateProfile(userID uuid.UUID, req *dto.UpdateProfileRequest, ifMatch etag.Condition) (*models.User, error) {
+	for attempt := 1; ; attempt++ {
+		user, err := s.repo.FindByID(userID)
+		if err != nil {
+			return nil, err
+		}
+		if !ifMatch.Matches(user.Version) {
+			return nil, repository.ErrUserVersionConflict
+		}
+
+		user.Name = req.Name
+		user.UpdatedAt = time.Now()
+
+		err = s.repo.Update(user)
+		if errors.Is(err, repository.ErrUserVersionConflict) && ifMatch == nil && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
 }
 
 func (s *userService) GetUsers(page, limit int, role string) ([]*models.User, int, error) {
 	return s.repo.FindAll(page, limit, role)
 }
+
+func (s *userService) GetUsersPage(role string, seek *repository.UserSeek, limit int) ([]*models.User, bool, error) {
+	return s.repo.FindPage(role, seek, limit)
+}
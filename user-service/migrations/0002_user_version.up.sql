@@ -0,0 +1,2 @@
+-- Версия для оптимистичных блокировок: существующие пользователи начинают с 1
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
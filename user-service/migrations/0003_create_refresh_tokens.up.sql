@@ -0,0 +1,17 @@
+-- Refresh-токены хранятся только хешем; строки обменянных и отозванных токенов остаются
+-- до истечения, чтобы распознать повторное предъявление
+CREATE TABLE refresh_tokens (
+    id         VARCHAR(36) PRIMARY KEY,
+    family_id  VARCHAR(36) NOT NULL,
+    user_id    VARCHAR(36) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP   NOT NULL,
+    expires_at TIMESTAMP   NOT NULL,
+    used_at    TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX refresh_tokens_token_hash_idx ON refresh_tokens (token_hash);
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+CREATE INDEX refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS revoked_users;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS revocation_state;
@@ -0,0 +1,28 @@
+-- Список отозванных access-токенов. Каждое изменение получает следующую версию из
+-- revocation_state, по ней шлюз и сервисы забирают только новые записи.
+-- Эпоха записывается сервисом при первом обращении и меняется, только если список создан заново.
+CREATE TABLE revocation_state (
+    id      INTEGER     PRIMARY KEY CHECK (id = 1),
+    epoch   VARCHAR(36) NOT NULL,
+    version BIGINT      NOT NULL
+);
+
+CREATE TABLE revoked_tokens (
+    jti        VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP   NOT NULL,
+    version    BIGINT      NOT NULL
+);
+
+CREATE INDEX revoked_tokens_version_idx ON revoked_tokens (version);
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+-- Все токены пользователя, выданные до revoked_before; запись нужна до expires_at
+CREATE TABLE revoked_users (
+    user_id        VARCHAR(36) PRIMARY KEY,
+    revoked_before TIMESTAMP   NOT NULL,
+    expires_at     TIMESTAMP   NOT NULL,
+    version        BIGINT      NOT NULL
+);
+
+CREATE INDEX revoked_users_version_idx ON revoked_users (version);
+CREATE INDEX revoked_users_expires_at_idx ON revoked_users (expires_at);
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken хранит только хеш токена: сам токен знает лишь клиент.
+// Токены, полученные последовательными обновлениями от одного входа, образуют семейство (FamilyID).
+type RefreshToken struct {
+	ID        uuid.UUID
+	FamilyID  uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// Когда токен был обменян на новый; повторное предъявление означает кражу
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
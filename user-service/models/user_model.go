@@ -15,6 +15,8 @@ type User struct {
 	Roles     []string  `json:"roles"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+	// Version растёт с каждым сохранением: по нему проверяются параллельные изменения (ETag)
+	Version int64 `json:"version"`
 }
 
 func NewUser(email, password, name string) (*User, error) {
@@ -29,6 +31,7 @@ func NewUser(email, password, name string) (*User, error) {
 		Password:  string(hashedPassword),
 		Name:      name,
 		Roles:     []string{"user"},
+		Version:   1,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}, nil
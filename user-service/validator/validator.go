@@ -36,3 +36,10 @@ func ValidateLoginRequest(req *dto.LoginRequest) error {
 	}
 	return nil
 }
+
+func ValidateRefreshTokenRequest(req *dto.RefreshTokenRequest) error {
+	if req.RefreshToken == "" {
+		return errors.New("refreshToken is required")
+	}
+	return nil
+}